@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"github.com/cappstr/GopherSocial/internal/apiserver"
+	"github.com/cappstr/GopherSocial/internal/blob"
 	"github.com/cappstr/GopherSocial/internal/config"
+	"github.com/cappstr/GopherSocial/internal/linkpreview"
 	"github.com/cappstr/GopherSocial/internal/store"
+	"github.com/cappstr/GopherSocial/internal/worker"
 	"io"
 	"log/slog"
 	"os"
@@ -22,7 +25,7 @@ func run(ctx context.Context, w io.Writer) error {
 
 	cfg, err := config.NewConfig()
 	if err != nil {
-		fmt.Fprintf(w, "%s\n", err)
+		return err
 	}
 
 	db, err := store.NewPostgresDb(cfg)
@@ -34,7 +37,23 @@ func run(ctx context.Context, w io.Writer) error {
 
 	jwtManager := apiserver.NewJwtManager(cfg)
 
-	server := apiserver.New(cfg, logger, dataStore, jwtManager)
+	workers := worker.NewPool(logger, cfg.Workers, cfg.WorkerQueueSize)
+	go workers.Run(ctx)
+
+	blobs, err := blob.NewBlobStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	previews := linkpreview.NewFetcher(linkpreview.Options{
+		Timeout:      cfg.LinkPreviewTimeout,
+		MaxBytes:     cfg.LinkPreviewMaxBytes,
+		MaxRedirects: cfg.LinkPreviewMaxRedirects,
+	})
+
+	cursors := store.NewCursorCodec(cfg.CursorSecret)
+
+	server := apiserver.New(cfg, logger, dataStore, jwtManager, workers, blobs, previews, cursors)
 	if err := server.Start(ctx); err != nil {
 		fmt.Fprintf(w, "%s\n", err)
 	}
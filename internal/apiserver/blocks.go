@@ -0,0 +1,133 @@
+package apiserver
+
+import (
+	"context"
+	"errors"
+	"github.com/cappstr/GopherSocial/internal/store"
+	"log/slog"
+	"net/http"
+)
+
+// BlockHandler blocks a user for the signed-in user. Blocking ends the follows between the two
+// in both directions, and from then on neither sees the other's posts, comments or
+// notifications or can interact with them.
+func (s *ApiServer) BlockHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.anyUserFromPath(w, r)
+	if !ok {
+		return
+	}
+	created, err := s.store.Blocks.Block(r.Context(), userFromContext(r.Context()).Id, user.Id)
+	if errors.Is(err, store.ErrSelfBlock) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to block user", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	status, message := http.StatusCreated, "successfully blocked user"
+	if !created {
+		status, message = http.StatusOK, "already blocked user"
+	}
+	if err := Encode(ApiResponse[struct{}]{Message: message}, w, status); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// UnblockHandler lifts a block. Follows the block ended stay ended.
+func (s *ApiServer) UnblockHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.anyUserFromPath(w, r)
+	if !ok {
+		return
+	}
+	if _, err := s.store.Blocks.Unblock(r.Context(), userFromContext(r.Context()).Id, user.Id); err != nil {
+		slog.Error("failed to unblock user", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MuteHandler mutes a user for the signed-in user, silently hiding their posts, reposts,
+// comments and notifications. The muted user isn't told and can still follow and interact.
+func (s *ApiServer) MuteHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.anyUserFromPath(w, r)
+	if !ok {
+		return
+	}
+	created, err := s.store.Blocks.Mute(r.Context(), userFromContext(r.Context()).Id, user.Id)
+	if errors.Is(err, store.ErrSelfBlock) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to mute user", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	status, message := http.StatusCreated, "successfully muted user"
+	if !created {
+		status, message = http.StatusOK, "already muted user"
+	}
+	if err := Encode(ApiResponse[struct{}]{Message: message}, w, status); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// UnmuteHandler lifts a mute, bringing the posts of the user back into the timeline of the
+// signed-in user if they follow them.
+func (s *ApiServer) UnmuteHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.anyUserFromPath(w, r)
+	if !ok {
+		return
+	}
+	muter := userFromContext(r.Context())
+	removed, err := s.store.Blocks.Unmute(r.Context(), muter.Id, user.Id)
+	if err != nil {
+		slog.Error("failed to unmute user", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if removed {
+		s.backfillTimeline(muter.Id, user.Id)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListBlocksHandler lists the users the signed-in user blocked.
+func (s *ApiServer) ListBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	s.listBlocks(w, r, s.store.Blocks.ListBlocked)
+}
+
+// ListMutesHandler lists the users the signed-in user muted.
+func (s *ApiServer) ListMutesHandler(w http.ResponseWriter, r *http.Request) {
+	s.listBlocks(w, r, s.store.Blocks.ListMuted)
+}
+
+func (s *ApiServer) listBlocks(w http.ResponseWriter, r *http.Request,
+	list func(ctx context.Context, userId int, page store.Page) ([]store.BlockEntry, *store.Cursor, error)) {
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, next, err := list(r.Context(), userFromContext(r.Context()).Id, page)
+	if err != nil {
+		slog.Error("failed to list blocked users", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.BlockEntry]{
+		Data:       &entries,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,244 @@
+package apiserver
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/cappstr/GopherSocial/internal/store"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxCollectionName is the longest name a bookmark collection can have, in characters.
+const maxCollectionName = 100
+
+type BookmarkRequest struct {
+	// CollectionId is the id of one of the user's collections to file the bookmark in. Bookmarks
+	// without one are unsorted.
+	CollectionId string `json:"collection_id,omitempty"`
+}
+
+func (req BookmarkRequest) Validate() error {
+	return nil
+}
+
+type CollectionRequest struct {
+	Name string `json:"name"`
+}
+
+func (req CollectionRequest) Validate() error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return errors.New("name is required")
+	}
+	if utf8.RuneCountInString(name) > maxCollectionName {
+		return errors.New("name must be at most 100 characters")
+	}
+	return nil
+}
+
+// BookmarkHandler bookmarks a post for the signed-in user, filing it in the collection named in
+// the request. Bookmarks are private, so the author isn't notified.
+func (s *ApiServer) BookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := Decode[BookmarkRequest](r)
+	if err != nil {
+		slog.Error("failed to decode request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	post, ok := s.postFromPath(w, r)
+	if !ok {
+		return
+	}
+	user := userFromContext(r.Context())
+	var collectionId *int
+	if req.CollectionId != "" {
+		collection, err := s.store.Bookmarks.GetCollection(r.Context(), req.CollectionId, user.Id)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "collection not found", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			slog.Error("failed to get bookmark collection", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		collectionId = &collection.Id
+	}
+	if err := s.store.Bookmarks.Bookmark(r.Context(), user.Id, post.Id, collectionId); err != nil {
+		slog.Error("failed to bookmark post", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *ApiServer) RemoveBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	post, ok := s.postFromPath(w, r)
+	if !ok {
+		return
+	}
+	if err := s.store.Bookmarks.Remove(r.Context(), userFromContext(r.Context()).Id, post.Id); err != nil {
+		slog.Error("failed to remove bookmark", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListBookmarksHandler lists the posts the signed-in user bookmarked, only those in the
+// collection named by the "collection" query parameter when it is set.
+func (s *ApiServer) ListBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user := userFromContext(r.Context())
+	var collectionId *int
+	if publicId := r.URL.Query().Get("collection"); publicId != "" {
+		collection, err := s.store.Bookmarks.GetCollection(r.Context(), publicId, user.Id)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.Error("failed to get bookmark collection", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		collectionId = &collection.Id
+	}
+	posts, next, err := s.store.Bookmarks.List(r.Context(), user.Id, collectionId, page)
+	if err != nil {
+		slog.Error("failed to list bookmarks", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydratePosts(r.Context(), postPtrs(posts)...); err != nil {
+		slog.Error("failed to hydrate posts", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.Posts]{
+		Data:       &posts,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (s *ApiServer) CreateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := Decode[CollectionRequest](r)
+	if err != nil {
+		slog.Error("failed to decode request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	collection, err := s.store.Bookmarks.CreateCollection(r.Context(), userFromContext(r.Context()).Id,
+		strings.TrimSpace(req.Name))
+	if errors.Is(err, store.ErrCollectionExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to create bookmark collection", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[store.BookmarkCollection]{
+		Data:    collection,
+		Message: "successfully created collection",
+	}, w, http.StatusCreated); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// ListCollectionsHandler lists the signed-in user's bookmark collections.
+func (s *ApiServer) ListCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	collections, next, err := s.store.Bookmarks.ListCollections(r.Context(), userFromContext(r.Context()).Id, page)
+	if err != nil {
+		slog.Error("failed to list bookmark collections", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.BookmarkCollection]{
+		Data:       &collections,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// collectionFromPath loads the signed-in user's collection named by the "id" path value. It
+// writes the error response and returns false when the collection can't be loaded.
+func (s *ApiServer) collectionFromPath(w http.ResponseWriter, r *http.Request) (*store.BookmarkCollection, bool) {
+	collection, err := s.store.Bookmarks.GetCollection(r.Context(), r.PathValue("id"), userFromContext(r.Context()).Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		slog.Error("failed to get bookmark collection", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return collection, true
+}
+
+func (s *ApiServer) RenameCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := Decode[CollectionRequest](r)
+	if err != nil {
+		slog.Error("failed to decode request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	collection, ok := s.collectionFromPath(w, r)
+	if !ok {
+		return
+	}
+	collection, err = s.store.Bookmarks.RenameCollection(r.Context(), collection.Id, strings.TrimSpace(req.Name))
+	if errors.Is(err, store.ErrCollectionExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to rename bookmark collection", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[store.BookmarkCollection]{
+		Data:    collection,
+		Message: "successfully renamed collection",
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// DeleteCollectionHandler deletes a bookmark collection, keeping its bookmarks unsorted.
+func (s *ApiServer) DeleteCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	collection, ok := s.collectionFromPath(w, r)
+	if !ok {
+		return
+	}
+	if err := s.store.Bookmarks.DeleteCollection(r.Context(), collection.Id); err != nil {
+		slog.Error("failed to delete bookmark collection", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
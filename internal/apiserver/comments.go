@@ -0,0 +1,359 @@
+package apiserver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/cappstr/GopherSocial/internal/store"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+const (
+	maxReplyDepth  = 5
+	maxThreadDepth = 50
+)
+
+type CommentRequest struct {
+	Content  string `json:"content"`
+	ParentId string `json:"parent_id,omitempty"`
+}
+
+func (req CommentRequest) Validate() error {
+	if req.Content == "" {
+		return errors.New("content is required")
+	}
+	return nil
+}
+
+// commentFromPath loads the comment named by the "commentId" path value and checks that it
+// belongs to post. It writes the error response and returns false when the comment can't be
+// used, which includes comments by users the signed-in user blocked or was blocked by.
+func (s *ApiServer) commentFromPath(w http.ResponseWriter, r *http.Request, post *store.Posts) (*store.Comment, bool) {
+	return s.commentOnPost(w, r, post, r.PathValue("commentId"))
+}
+
+func (s *ApiServer) commentOnPost(w http.ResponseWriter, r *http.Request, post *store.Posts, publicId string) (*store.Comment, bool) {
+	comment, err := s.store.Comments.GetCommentByPublicId(r.Context(), publicId, userFromContext(r.Context()).Id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && comment.PostId != post.Id) {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		slog.Error("failed to get comment", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return comment, true
+}
+
+// extractCommentEntities stores the @mentions parsed out of the content of comment and notifies
+// the users mentioned.
+func (s *ApiServer) extractCommentEntities(ctx context.Context, comment *store.Comment) error {
+	mentioned, err := s.storeMentions(ctx, store.CommentMentions, comment.Id, comment.Content)
+	if err != nil {
+		return err
+	}
+	return s.notifyMentioned(ctx, mentioned, store.NewNotification{
+		ActorId:   comment.UserId,
+		Kind:      store.NotificationMention,
+		PostId:    &comment.PostId,
+		CommentId: &comment.Id,
+	})
+}
+
+func (s *ApiServer) CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := Decode[CommentRequest](r)
+	if err != nil {
+		slog.Error("failed to decode request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	post, ok := s.postFromPath(w, r)
+	if !ok {
+		return
+	}
+	var parentId *int
+	if req.ParentId != "" {
+		parent, ok := s.commentOnPost(w, r, post, req.ParentId)
+		if !ok {
+			return
+		}
+		parentId = &parent.Id
+	}
+	user := userFromContext(r.Context())
+	comment, err := s.store.Comments.CreateComment(r.Context(), post.Id, user.Id, parentId, req.Content)
+	if err != nil {
+		slog.Error("failed to create comment", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.extractCommentEntities(r.Context(), comment); err != nil {
+		slog.Error("failed to extract comment entities", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydrateComments(r.Context(), comment); err != nil {
+		slog.Error("failed to hydrate comments", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[store.Comment]{
+		Data:    comment,
+		Message: "successfully created comment",
+	}, w, http.StatusCreated); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (s *ApiServer) ListCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	post, ok := s.postFromPath(w, r)
+	if !ok {
+		return
+	}
+	depth, replies, err := s.replyExpansion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	viewerId := userFromContext(r.Context()).Id
+	comments, next, err := s.store.Comments.ListCommentsByPost(r.Context(), post.Id, viewerId, page)
+	if err != nil {
+		slog.Error("failed to list comments", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.store.Comments.ExpandReplies(r.Context(), comments, viewerId, depth, replies); err != nil {
+		slog.Error("failed to expand replies", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydrateComments(r.Context(), commentPtrs(comments)...); err != nil {
+		slog.Error("failed to hydrate comments", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.Comment]{
+		Data:       &comments,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// replyExpansion reads the "depth" and "replies" query parameters: how many levels of replies to
+// nest under each listed comment, and how many replies to load per comment at each level.
+func (s *ApiServer) replyExpansion(r *http.Request) (int, int, error) {
+	depth, replies := 0, 3
+	if v := r.URL.Query().Get("depth"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("depth must be a non-negative integer")
+		}
+		depth = min(n, maxReplyDepth)
+	}
+	if v := r.URL.Query().Get("replies"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return 0, 0, errors.New("replies must be a positive integer")
+		}
+		replies = min(n, s.config.MaxPageSize)
+	}
+	return depth, replies, nil
+}
+
+// ListRepliesHandler pages through the direct replies to a comment, which is how clients load
+// more replies after a comment's replies_next_cursor.
+func (s *ApiServer) ListRepliesHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	depth, replies, err := s.replyExpansion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	post, ok := s.postFromPath(w, r)
+	if !ok {
+		return
+	}
+	parent, ok := s.commentFromPath(w, r, post)
+	if !ok {
+		return
+	}
+	viewerId := userFromContext(r.Context()).Id
+	comments, next, err := s.store.Comments.ListReplies(r.Context(), parent.Id, viewerId, page)
+	if err != nil {
+		slog.Error("failed to list replies", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.store.Comments.ExpandReplies(r.Context(), comments, viewerId, depth, replies); err != nil {
+		slog.Error("failed to expand replies", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydrateComments(r.Context(), commentPtrs(comments)...); err != nil {
+		slog.Error("failed to hydrate comments", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.Comment]{
+		Data:       &comments,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// ThreadHandler returns a comment with all of its replies as a tree, down to the "depth" query
+// parameter.
+func (s *ApiServer) ThreadHandler(w http.ResponseWriter, r *http.Request) {
+	depth := maxThreadDepth
+	if v := r.URL.Query().Get("depth"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "depth must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		depth = min(n, maxThreadDepth)
+	}
+	post, ok := s.postFromPath(w, r)
+	if !ok {
+		return
+	}
+	root, ok := s.commentFromPath(w, r, post)
+	if !ok {
+		return
+	}
+	thread, err := s.store.Comments.GetThread(r.Context(), root.Id, userFromContext(r.Context()).Id, depth)
+	if err != nil {
+		slog.Error("failed to get thread", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydrateComments(r.Context(), thread); err != nil {
+		slog.Error("failed to hydrate comments", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[store.Comment]{Data: thread}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (s *ApiServer) UpdateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := Decode[CommentRequest](r)
+	if err != nil {
+		slog.Error("failed to decode request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	post, ok := s.postFromPath(w, r)
+	if !ok {
+		return
+	}
+	comment, ok := s.commentFromPath(w, r, post)
+	if !ok {
+		return
+	}
+	if comment.UserId != userFromContext(r.Context()).Id {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	comment, err = s.store.Comments.UpdateComment(r.Context(), comment.Id, req.Content)
+	if err != nil {
+		slog.Error("failed to update comment", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.extractCommentEntities(r.Context(), comment); err != nil {
+		slog.Error("failed to extract comment entities", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydrateComments(r.Context(), comment); err != nil {
+		slog.Error("failed to hydrate comments", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[store.Comment]{
+		Data:    comment,
+		Message: "successfully updated comment",
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (s *ApiServer) DeleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	post, ok := s.postFromPath(w, r)
+	if !ok {
+		return
+	}
+	comment, ok := s.commentFromPath(w, r, post)
+	if !ok {
+		return
+	}
+	user := userFromContext(r.Context())
+	if comment.UserId != user.Id && !user.IsModerator() {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if err := s.store.Comments.DeleteComment(r.Context(), comment.Id, user.Id); err != nil {
+		slog.Error("failed to delete comment", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreCommentHandler restores a comment the signed-in user deleted from their own comments,
+// along with the replies deleted with it, while it is within the trash retention period.
+func (s *ApiServer) RestoreCommentHandler(w http.ResponseWriter, r *http.Request) {
+	post, ok := s.postFromPath(w, r)
+	if !ok {
+		return
+	}
+	comment, err := s.store.Comments.RestoreComment(r.Context(), post.Id, r.PathValue("commentId"),
+		userFromContext(r.Context()).Id, s.config.TrashRetention)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to restore comment", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydrateComments(r.Context(), comment); err != nil {
+		slog.Error("failed to hydrate comments", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[store.Comment]{
+		Data:    comment,
+		Message: "successfully restored comment",
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
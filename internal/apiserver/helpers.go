@@ -2,17 +2,24 @@ package apiserver
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/cappstr/GopherSocial/internal/store"
 	"net/http"
+	"strconv"
 )
 
+// maxRequestBody caps the size of JSON request bodies.
+const maxRequestBody = 1 << 20
+
 type Validator interface {
 	Validate() error
 }
 
 type ApiResponse[T any] struct {
-	Data    *T     `json:"data,omitempty"`
-	Message string `json:"message,omitempty"`
+	Data       *T     `json:"data,omitempty"`
+	Message    string `json:"message,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 func Encode[T any](v T, w http.ResponseWriter, status int) error {
@@ -26,7 +33,8 @@ func Encode[T any](v T, w http.ResponseWriter, status int) error {
 
 func Decode[T Validator](r *http.Request) (T, error) {
 	var v T
-	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+	body := http.MaxBytesReader(nil, r.Body, maxRequestBody)
+	if err := json.NewDecoder(body).Decode(&v); err != nil {
 		return v, fmt.Errorf("error decoding json: %w", err)
 	}
 	if err := v.Validate(); err != nil {
@@ -34,3 +42,32 @@ func Decode[T Validator](r *http.Request) (T, error) {
 	}
 	return v, nil
 }
+
+// pageFromRequest reads the "limit" and "cursor" query parameters, defaulting and capping the
+// limit with the configured page sizes.
+func (s *ApiServer) pageFromRequest(r *http.Request) (store.Page, error) {
+	page := store.Page{Limit: s.config.DefaultPageSize}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return page, errors.New("limit must be a positive integer")
+		}
+		page.Limit = min(n, s.config.MaxPageSize)
+	}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		c, err := s.cursors.Decode(cursor)
+		if err != nil {
+			return page, err
+		}
+		page.Cursor = c
+	}
+	return page, nil
+}
+
+// encodeCursor returns the encoded cursor, or an empty string on the last page.
+func (s *ApiServer) encodeCursor(c *store.Cursor) string {
+	if c == nil {
+		return ""
+	}
+	return s.cursors.Encode(*c)
+}
@@ -0,0 +1,26 @@
+package apiserver
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeLimitsBody(t *testing.T) {
+	content := strings.Repeat("a", maxRequestBody)
+	r := httptest.NewRequest("POST", "/v1/posts", strings.NewReader(`{"title":"t","content":"`+content+`"}`))
+	if _, err := Decode[PostRequest](r); err == nil {
+		t.Fatal("decoded a body over the limit")
+	}
+}
+
+func TestPostRequestLimitsContent(t *testing.T) {
+	req := PostRequest{Title: "t", Content: strings.Repeat("a", maxPostContent)}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("content at the limit: %v", err)
+	}
+	req.Content += "a"
+	if err := req.Validate(); err == nil {
+		t.Fatal("validated content over the limit")
+	}
+}
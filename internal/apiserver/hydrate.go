@@ -0,0 +1,190 @@
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"github.com/cappstr/GopherSocial/internal/store"
+	"slices"
+)
+
+// hydratePosts fills in the fields of posts that are loaded separately from the posts
+// themselves, including those that depend on the signed-in viewer in ctx. The posts quoted by
+// posts are loaded and hydrated along with them, but not the posts those quote in turn.
+func (s *ApiServer) hydratePosts(ctx context.Context, posts ...*store.Posts) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	quoted, err := s.loadQuotes(ctx, posts)
+	if err != nil {
+		return fmt.Errorf("failed to hydrate posts: %w", err)
+	}
+	posts = append(slices.Clip(posts), quoted...)
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		ids[i] = post.Id
+	}
+	counts, err := s.store.Reactions.Counts(ctx, store.PostReactions, ids)
+	if err != nil {
+		return fmt.Errorf("failed to hydrate posts: %w", err)
+	}
+	mentions, err := s.store.Mentions.ForTargets(ctx, store.PostMentions, ids)
+	if err != nil {
+		return fmt.Errorf("failed to hydrate posts: %w", err)
+	}
+	media, err := s.store.Media.ForPosts(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to hydrate posts: %w", err)
+	}
+	links, err := s.store.LinkPreviews.ForPosts(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to hydrate posts: %w", err)
+	}
+	var mine map[int][]string
+	var reposted, bookmarked map[int]bool
+	if viewer := userFromContext(ctx); viewer != nil {
+		if mine, err = s.store.Reactions.UserReactions(ctx, store.PostReactions, ids, viewer.Id); err != nil {
+			return fmt.Errorf("failed to hydrate posts: %w", err)
+		}
+		if reposted, err = s.store.Reposts.RepostedBy(ctx, ids, viewer.Id); err != nil {
+			return fmt.Errorf("failed to hydrate posts: %w", err)
+		}
+		if bookmarked, err = s.store.Bookmarks.BookmarkedBy(ctx, ids, viewer.Id); err != nil {
+			return fmt.Errorf("failed to hydrate posts: %w", err)
+		}
+	}
+	for _, post := range posts {
+		post.Reactions = reactionCounts(counts[post.Id])
+		post.MyReactions = mine[post.Id]
+		post.Reposted = reposted[post.Id]
+		post.Bookmarked = bookmarked[post.Id]
+		post.Mentions = mentionEntities(mentions[post.Id])
+		post.Media = s.signedMedia(media[post.Id])
+		post.Links = linkPreviews(links[post.Id])
+	}
+	return nil
+}
+
+// loadQuotes sets Quote on those of posts that quote a post the viewer in ctx can still see,
+// and returns the quoted posts, each once.
+func (s *ApiServer) loadQuotes(ctx context.Context, posts []*store.Posts) ([]*store.Posts, error) {
+	var ids []int
+	for _, post := range posts {
+		if post.QuoteOf != nil {
+			ids = append(ids, *post.QuoteOf)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	listed, err := s.store.Posts.GetListedPostsByIds(ctx, ids, userFromContext(ctx).Id)
+	if err != nil {
+		return nil, err
+	}
+	quoted := postPtrs(listed)
+	byId := make(map[int]*store.Posts, len(quoted))
+	for _, q := range quoted {
+		byId[q.Id] = q
+	}
+	for _, post := range posts {
+		if post.QuoteOf != nil {
+			post.Quote = byId[*post.QuoteOf]
+		}
+	}
+	return quoted, nil
+}
+
+// hydrateComments fills in the viewer-independent and viewer-dependent fields of comments and
+// of all replies nested beneath them.
+func (s *ApiServer) hydrateComments(ctx context.Context, comments ...*store.Comment) error {
+	var all []*store.Comment
+	var walk func(cs []*store.Comment)
+	walk = func(cs []*store.Comment) {
+		for _, c := range cs {
+			all = append(all, c)
+			walk(c.Replies)
+		}
+	}
+	walk(comments)
+	if len(all) == 0 {
+		return nil
+	}
+	ids := make([]int, len(all))
+	for i, c := range all {
+		ids[i] = c.Id
+	}
+	counts, err := s.store.Reactions.Counts(ctx, store.CommentReactions, ids)
+	if err != nil {
+		return fmt.Errorf("failed to hydrate comments: %w", err)
+	}
+	mentions, err := s.store.Mentions.ForTargets(ctx, store.CommentMentions, ids)
+	if err != nil {
+		return fmt.Errorf("failed to hydrate comments: %w", err)
+	}
+	var mine map[int][]string
+	if viewer := userFromContext(ctx); viewer != nil {
+		if mine, err = s.store.Reactions.UserReactions(ctx, store.CommentReactions, ids, viewer.Id); err != nil {
+			return fmt.Errorf("failed to hydrate comments: %w", err)
+		}
+	}
+	for _, c := range all {
+		c.Reactions = reactionCounts(counts[c.Id])
+		c.MyReactions = mine[c.Id]
+		c.Mentions = mentionEntities(mentions[c.Id])
+		c.RepliesNextCursor = s.encodeCursor(c.RepliesNext)
+	}
+	return nil
+}
+
+// postPtrs returns pointers into posts for hydratePosts.
+func postPtrs(posts []store.Posts) []*store.Posts {
+	ptrs := make([]*store.Posts, len(posts))
+	for i := range posts {
+		ptrs[i] = &posts[i]
+	}
+	return ptrs
+}
+
+// commentPtrs returns pointers into comments for hydrateComments.
+func commentPtrs(comments []store.Comment) []*store.Comment {
+	ptrs := make([]*store.Comment, len(comments))
+	for i := range comments {
+		ptrs[i] = &comments[i]
+	}
+	return ptrs
+}
+
+// reactionCounts keeps posts and comments without reactions encoding as an empty object.
+func reactionCounts(counts map[string]int) map[string]int {
+	if counts == nil {
+		return map[string]int{}
+	}
+	return counts
+}
+
+// signedMedia gives each of media its download URLs, and keeps posts without media encoding as
+// an empty array.
+func (s *ApiServer) signedMedia(media []store.Media) []store.Media {
+	for i := range media {
+		s.signMedia(&media[i])
+	}
+	if media == nil {
+		return []store.Media{}
+	}
+	return media
+}
+
+// linkPreviews keeps posts without link previews encoding as an empty array.
+func linkPreviews(previews []store.LinkPreview) []store.LinkPreview {
+	if previews == nil {
+		return []store.LinkPreview{}
+	}
+	return previews
+}
+
+// mentionEntities keeps content without mentions encoding as an empty array.
+func mentionEntities(mentions []store.MentionEntity) []store.MentionEntity {
+	if mentions == nil {
+		return []store.MentionEntity{}
+	}
+	return mentions
+}
@@ -0,0 +1,325 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/cappstr/GopherSocial/internal/imaging"
+	"github.com/cappstr/GopherSocial/internal/store"
+	"github.com/cappstr/GopherSocial/internal/worker"
+	"io"
+	"time"
+)
+
+const (
+	// timelineBackfillSize is how many recent posts of a newly followed account are copied
+	// into the follower's home timeline.
+	timelineBackfillSize = 50
+	// trendingTagsSize is how many tags are kept for each trending window.
+	trendingTagsSize = 50
+	// publishBatchSize is how many due posts the scheduler publishes per query.
+	publishBatchSize = 100
+	// purgeBatchSize is how many deleted posts, and comments, are purged per query.
+	purgeBatchSize = 500
+	// unattachedMediaTTL is how long uploads may stay unattached to a post before they are purged.
+	unattachedMediaTTL = 24 * time.Hour
+	// deliveryTimeout is how long a delivery may wait for its job, or run, before it is retried.
+	deliveryTimeout = time.Minute
+	// linkPreviewClaimTimeout is how long a link preview may wait for its job, or be fetched,
+	// before it is queued again.
+	linkPreviewClaimTimeout = time.Minute
+)
+
+// trendingWindows are the time windows trending tags are computed over, by name.
+var trendingWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+// startScheduledJobs starts the periodic background jobs, which stop when ctx is cancelled.
+func (s *ApiServer) startScheduledJobs(ctx context.Context) {
+	go worker.Every(ctx, s.logger, "trending tags", s.config.TrendingInterval, s.recomputeTrending)
+	go worker.Every(ctx, s.logger, "scheduled posts", s.config.SchedulerInterval, s.publishDuePosts)
+	go worker.Every(ctx, s.logger, "purge deleted", s.config.PurgeInterval, s.purgeDeleted)
+	go worker.Every(ctx, s.logger, "purge media", s.config.PurgeInterval, s.purgeMedia)
+	go worker.Every(ctx, s.logger, "requeue media", s.config.MediaSweepInterval, s.requeueMedia)
+	go worker.Every(ctx, s.logger, "requeue deliveries", s.config.DeliverySweepInterval, s.requeueDeliveries)
+	go worker.Every(ctx, s.logger, "requeue link previews", s.config.LinkPreviewSweepInterval,
+		s.requeueLinkPreviews)
+}
+
+// processMedia queues processing the uploaded image id.
+func (s *ApiServer) processMedia(id int) {
+	s.workers.Submit("process media", func(ctx context.Context) error {
+		return s.runMediaProcessing(ctx, id)
+	})
+}
+
+// runMediaProcessing claims the uploaded image id and replaces it with its processed version,
+// storing its thumbnails alongside. Images that can't be processed are marked failed and are
+// never served. If the media was already claimed by another worker it does nothing.
+func (s *ApiServer) runMediaProcessing(ctx context.Context, id int) error {
+	media, err := s.store.Media.Claim(ctx, id, mediaProcessingTimeout)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, mediaProcessingTimeout)
+	defer cancel()
+
+	content, err := s.blobs.Get(ctx, media.StorageKey)
+	if err != nil {
+		return fmt.Errorf("failed to read media %d: %w", id, err)
+	}
+	data, err := io.ReadAll(content)
+	content.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read media %d: %w", id, err)
+	}
+	result, err := imaging.Process(data, thumbnailSizes)
+	if err != nil {
+		if err := s.store.Media.Fail(ctx, id); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to process media %d: %w", id, err)
+	}
+
+	processed := store.ProcessedMedia{
+		ContentType: result.Image.ContentType,
+		Size:        int64(len(result.Image.Data)),
+		Width:       result.Image.Width,
+		Height:      result.Image.Height,
+		Blurhash:    result.Blurhash,
+	}
+	for _, t := range result.Thumbnails {
+		key := media.StorageKey + "-" + t.Name
+		if err := s.blobs.Put(ctx, key, bytes.NewReader(t.Data), int64(len(t.Data)), t.ContentType); err != nil {
+			return fmt.Errorf("failed to store thumbnail of media %d: %w", id, err)
+		}
+		processed.Thumbnails = append(processed.Thumbnails, store.MediaThumbnail{
+			Name:        t.Name,
+			StorageKey:  key,
+			ContentType: t.ContentType,
+			Width:       t.Width,
+			Height:      t.Height,
+			Size:        int64(len(t.Data)),
+		})
+	}
+	if err := s.blobs.Put(ctx, media.StorageKey, bytes.NewReader(result.Image.Data), processed.Size,
+		processed.ContentType); err != nil {
+		return fmt.Errorf("failed to store processed media %d: %w", id, err)
+	}
+	return s.store.Media.Complete(ctx, id, processed)
+}
+
+// queueLinkPreviews queues fetching the previews of the links in the published post postId that
+// aren't cached or have expired. Drafts aren't previewed: fetching would tell the linked sites
+// about posts nobody else can see yet.
+func (s *ApiServer) queueLinkPreviews(ctx context.Context, postId int) error {
+	urls, err := s.store.LinkPreviews.Queue(ctx, postId)
+	if err != nil {
+		return err
+	}
+	s.fetchLinkPreviews(urls)
+	return nil
+}
+
+// fetchLinkPreviews queues fetching the previews of urls. Previews whose job is dropped are picked
+// up by requeueLinkPreviews.
+func (s *ApiServer) fetchLinkPreviews(urls []string) {
+	for _, u := range urls {
+		s.workers.Submit("link preview", func(ctx context.Context) error {
+			return s.fetchLinkPreview(ctx, u)
+		})
+	}
+}
+
+func (s *ApiServer) fetchLinkPreview(ctx context.Context, url string) error {
+	claimed, err := s.store.LinkPreviews.Claim(ctx, url, s.config.LinkPreviewTTL, linkPreviewClaimTimeout)
+	if err != nil || !claimed {
+		return err
+	}
+	preview, err := s.previews.Fetch(ctx, url)
+	if err != nil {
+		// Pages without previews are common, so this is not worth an error.
+		s.logger.Info("no link preview", "url", url, "reason", err)
+		return s.store.LinkPreviews.Fail(ctx, url)
+	}
+	return s.store.LinkPreviews.Save(ctx, url, preview.Title, preview.Description, preview.ImageURL,
+		preview.SiteName)
+}
+
+// requeueLinkPreviews queues fetching again the previews whose job was lost, or whose worker
+// stopped before finishing.
+func (s *ApiServer) requeueLinkPreviews(ctx context.Context) error {
+	urls, err := s.store.LinkPreviews.ListPending(ctx, linkPreviewClaimTimeout, s.config.WorkerQueueSize/2)
+	if err != nil {
+		return err
+	}
+	s.fetchLinkPreviews(urls)
+	return nil
+}
+
+// requeueMedia queues processing again for uploads whose job was lost, such as in a restart, or
+// whose worker stopped before finishing.
+func (s *ApiServer) requeueMedia(ctx context.Context) error {
+	ids, err := s.store.Media.ListUnprocessed(ctx, mediaProcessingTimeout, s.config.WorkerQueueSize/2)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		s.processMedia(id)
+	}
+	return nil
+}
+
+// purgeMedia deletes uploads that were never attached to a post, or whose post was purged,
+// along with their blobs.
+func (s *ApiServer) purgeMedia(ctx context.Context) error {
+	for {
+		media, err := s.store.Media.ListUnattached(ctx, time.Now().Add(-unattachedMediaTTL), purgeBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, m := range media {
+			keys, err := s.store.Media.Delete(ctx, m.Id)
+			if err != nil {
+				return err
+			}
+			for _, key := range keys {
+				if err := s.blobs.Delete(ctx, key); err != nil {
+					return fmt.Errorf("failed to delete blob of media %d: %w", m.Id, err)
+				}
+			}
+		}
+		if len(media) < purgeBatchSize {
+			return nil
+		}
+	}
+}
+
+// purgeDeleted permanently removes posts and comments that have been in the trash for longer
+// than the retention period.
+func (s *ApiServer) purgeDeleted(ctx context.Context) error {
+	for {
+		purged, err := s.store.Posts.PurgeDeleted(ctx, s.config.TrashRetention, purgeBatchSize)
+		if err != nil {
+			return err
+		}
+		if purged == 0 {
+			return nil
+		}
+	}
+}
+
+// publishDuePosts publishes scheduled posts whose time has come and runs their deliveries. It is
+// safe to run in several processes at once: PublishDue hands each post to only one of them.
+// Deliveries whose job is lost are picked up by requeueDeliveries.
+func (s *ApiServer) publishDuePosts(ctx context.Context) error {
+	for {
+		ids, err := s.store.Posts.PublishDue(ctx, publishBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			s.deliverPost(id)
+		}
+		if len(ids) < publishBatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *ApiServer) recomputeTrending(ctx context.Context) error {
+	for name, window := range trendingWindows {
+		if err := s.store.Tags.RecomputeTrending(ctx, name, window, trendingTagsSize); err != nil {
+			return fmt.Errorf("failed to recompute %s trending tags: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// deliverPost queues the delivery of the published post postId: notifying the users it mentions
+// and the author of the post it quotes, and inserting it into the home timelines of its author's
+// followers.
+func (s *ApiServer) deliverPost(postId int) {
+	s.workers.Submit("deliver post", func(ctx context.Context) error {
+		return s.runPostDelivery(ctx, postId)
+	})
+}
+
+// runPostDelivery claims and runs the pending delivery of postId. Every step is idempotent, so a
+// delivery retried after failing part way through does no harm. If the delivery isn't pending,
+// or another worker is running it, it does nothing.
+func (s *ApiServer) runPostDelivery(ctx context.Context, postId int) error {
+	delivery, err := s.store.Deliveries.ClaimPost(ctx, postId, deliveryTimeout)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	post, err := s.store.Posts.GetPostById(ctx, postId)
+	if err != nil {
+		return err
+	}
+	if post.Status == store.PostPublished && post.DeletedAt == nil {
+		if err := s.notifyPublished(ctx, post); err != nil {
+			return err
+		}
+		if _, err := s.store.Timelines.FanOut(ctx, postId, s.config.FanoutMaxFollowers); err != nil {
+			return err
+		}
+		if err := s.queueLinkPreviews(ctx, postId); err != nil {
+			return err
+		}
+	}
+	return s.store.Deliveries.Complete(ctx, delivery)
+}
+
+// deliverRepost queues the insertion of the post reposted by repostId into the home timelines
+// of the reposter's followers.
+func (s *ApiServer) deliverRepost(repostId int) {
+	s.workers.Submit("deliver repost", func(ctx context.Context) error {
+		delivery, err := s.store.Deliveries.ClaimRepost(ctx, repostId, deliveryTimeout)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := s.store.Timelines.FanOutRepost(ctx, repostId, s.config.FanoutMaxFollowers); err != nil {
+			return err
+		}
+		return s.store.Deliveries.Complete(ctx, delivery)
+	})
+}
+
+// requeueDeliveries queues deliveries again whose job was dropped by a full queue or lost in a
+// restart, or whose worker stopped before finishing.
+func (s *ApiServer) requeueDeliveries(ctx context.Context) error {
+	deliveries, err := s.store.Deliveries.ListDue(ctx, deliveryTimeout, s.config.WorkerQueueSize/2)
+	if err != nil {
+		return err
+	}
+	for _, d := range deliveries {
+		if d.PostId != nil {
+			s.deliverPost(*d.PostId)
+		} else {
+			s.deliverRepost(*d.RepostId)
+		}
+	}
+	return nil
+}
+
+// backfillTimeline queues copying recent posts of authorId into the home timeline of userId.
+func (s *ApiServer) backfillTimeline(userId, authorId int) {
+	s.workers.Submit("timeline backfill", func(ctx context.Context) error {
+		return s.store.Timelines.Backfill(ctx, userId, authorId, timelineBackfillSize)
+	})
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"github.com/cappstr/GopherSocial/internal/config"
 	"github.com/golang-jwt/jwt/v5"
-	"strconv"
 	"time"
 )
 
@@ -53,13 +52,13 @@ func (j *JwtManager) IsAccessToken(token *jwt.Token) bool {
 	return false
 }
 
-func (j *JwtManager) GenerateTokenPair(userId int) (*TokenPair, error) {
+func (j *JwtManager) GenerateTokenPair(userId string) (*TokenPair, error) {
 	var err error
 	jwtAccessToken := jwt.NewWithClaims(signingMethod, CustomClaims{
 		TokenType: "access",
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    j.cfg.ApiServerHost + ":" + j.cfg.ApiServerAddr,
-			Subject:   strconv.Itoa(userId),
+			Subject:   userId,
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 15)),
 		},
@@ -75,7 +74,7 @@ func (j *JwtManager) GenerateTokenPair(userId int) (*TokenPair, error) {
 		TokenType: "refresh",
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    j.cfg.ApiServerHost + ":" + j.cfg.ApiServerAddr,
-			Subject:   strconv.Itoa(userId),
+			Subject:   userId,
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * 30)),
 		},
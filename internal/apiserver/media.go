@@ -0,0 +1,216 @@
+package apiserver
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"github.com/cappstr/GopherSocial/internal/blob"
+	"github.com/cappstr/GopherSocial/internal/imaging"
+	"github.com/cappstr/GopherSocial/internal/store"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// mediaTypes are the content types accepted for upload, as sniffed from the uploaded bytes, and
+// whether they are images to be processed before they are served.
+var mediaTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+	"video/mp4":  false,
+}
+
+// thumbnailSizes are the thumbnails generated for images larger than them.
+var thumbnailSizes = []imaging.Size{
+	{Name: "small", MaxDim: 160},
+	{Name: "medium", MaxDim: 480},
+	{Name: "large", MaxDim: 1080},
+}
+
+// mediaProcessingTimeout is how long processing may take before another worker retries it.
+const mediaProcessingTimeout = 5 * time.Minute
+
+// sniffLen is how many bytes http.DetectContentType looks at.
+const sniffLen = 512
+
+// UploadMediaHandler stores the request body as a new upload of the signed-in user. The body is
+// the raw file; its type is sniffed from its contents rather than taken from the request. The
+// body is streamed into the blob store rather than held in memory, so its size must be given
+// up front in Content-Length.
+func (s *ApiServer) UploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength < 0 {
+		http.Error(w, "uploads need a Content-Length", http.StatusLengthRequired)
+		return
+	}
+	if r.ContentLength > s.config.MediaMaxBytes {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	if r.ContentLength == 0 {
+		http.Error(w, "upload is empty", http.StatusBadRequest)
+		return
+	}
+	size := r.ContentLength
+	body := bufio.NewReaderSize(http.MaxBytesReader(w, r.Body, s.config.MediaMaxBytes), sniffLen)
+	head, err := body.Peek(sniffLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		slog.Error("failed to read upload", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	contentType := http.DetectContentType(head)
+	process, ok := mediaTypes[contentType]
+	if !ok {
+		http.Error(w, "unsupported media type "+contentType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	key, err := newStorageKey()
+	if err != nil {
+		slog.Error("failed to generate storage key", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.blobs.Put(r.Context(), key, body, size, contentType); err != nil {
+		slog.Error("failed to store upload", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	status := store.MediaReady
+	if process {
+		status = store.MediaPending
+	}
+	media, err := s.store.Media.Create(r.Context(), userFromContext(r.Context()).Id, key, contentType,
+		size, status)
+	if err != nil {
+		slog.Error("failed to create media", "err", err)
+		if err := s.blobs.Delete(r.Context(), key); err != nil {
+			slog.Error("failed to delete upload", "err", err)
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if process {
+		s.processMedia(media.Id)
+	}
+	s.signMedia(media)
+
+	if err := Encode(ApiResponse[store.Media]{
+		Data:    media,
+		Message: "successfully uploaded media",
+	}, w, http.StatusCreated); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// DownloadMediaHandler serves the bytes of an upload, or of one of its thumbnails. It is reached
+// without a bearer token, so the URL must carry a valid, unexpired signature from mediaURL
+// instead. Uploads are only served once they are ready, so that images still carrying their
+// original metadata never leave the server.
+func (s *ApiServer) DownloadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	path := r.PathValue("id")
+	if thumbnail := r.PathValue("thumbnail"); thumbnail != "" {
+		path += "/" + thumbnail
+	}
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires ||
+		!hmac.Equal([]byte(r.URL.Query().Get("signature")), []byte(s.mediaSignature(path, expires))) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	media, err := s.store.Media.GetByPublicId(r.Context(), r.PathValue("id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to get media", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if media.Status != store.MediaReady {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key, contentType, size := media.StorageKey, media.ContentType, media.Size
+	if thumbnail := r.PathValue("thumbnail"); thumbnail != "" {
+		t, err := s.store.Media.GetThumbnail(r.Context(), media.Id, thumbnail)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			slog.Error("failed to get media thumbnail", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		key, contentType, size = t.StorageKey, t.ContentType, t.Size
+	}
+	content, err := s.blobs.Get(r.Context(), key)
+	if errors.Is(err, blob.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to open media", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.FormatInt(max(expires-time.Now().Unix(), 0), 10))
+	if _, err := io.Copy(w, content); err != nil {
+		slog.Error("failed to send media", "err", err)
+	}
+}
+
+// signMedia gives ready media, and its thumbnails, download URLs.
+func (s *ApiServer) signMedia(media *store.Media) {
+	if media.Status != store.MediaReady {
+		return
+	}
+	media.URL = s.mediaURL(media.PublicId)
+	for i := range media.Thumbnails {
+		media.Thumbnails[i].URL = s.mediaURL(media.PublicId + "/" + media.Thumbnails[i].Name)
+	}
+}
+
+// mediaURL returns a download URL for path, a media public id optionally followed by a
+// thumbnail name, that is valid for the configured TTL.
+func (s *ApiServer) mediaURL(path string) string {
+	expires := time.Now().Add(s.config.MediaUrlTTL).Unix()
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expires, 10)},
+		"signature": {s.mediaSignature(path, expires)},
+	}
+	return "/v1/files/" + path + "?" + query.Encode()
+}
+
+func (s *ApiServer) mediaSignature(path string, expires int64) string {
+	h := hmac.New(sha256.New, []byte(s.config.MediaUrlSecret))
+	h.Write([]byte(path + ":" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newStorageKey returns a random key to store an upload under, so that keys can't be guessed
+// or collide.
+func newStorageKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "media/" + hex.EncodeToString(b), nil
+}
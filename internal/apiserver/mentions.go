@@ -0,0 +1,75 @@
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"github.com/cappstr/GopherSocial/internal/entities"
+	"github.com/cappstr/GopherSocial/internal/store"
+	"slices"
+)
+
+// maxMentions is how many distinct usernames are resolved in one post or comment. Mentions of
+// usernames past the first maxMentions are left as plain text.
+const maxMentions = 10
+
+// resolveMentions looks up the users @mentioned in text, dropping mentions of usernames that
+// don't exist.
+func (s *ApiServer) resolveMentions(ctx context.Context, text string) ([]store.ResolvedMention, error) {
+	mentions := entities.Mentions(text)
+	var usernames []string
+	for _, mention := range mentions {
+		if !slices.Contains(usernames, mention.Username) && len(usernames) < maxMentions {
+			usernames = append(usernames, mention.Username)
+		}
+	}
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+	users, err := s.store.User.GetUsersByUsernames(ctx, usernames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve mentions: %w", err)
+	}
+	ids := make(map[string]int, len(users))
+	for _, user := range users {
+		ids[user.Username] = user.Id
+	}
+	var resolved []store.ResolvedMention
+	for _, mention := range mentions {
+		if id, ok := ids[mention.Username]; ok {
+			resolved = append(resolved, store.ResolvedMention{UserId: id, Start: mention.Start, End: mention.End})
+		}
+	}
+	return resolved, nil
+}
+
+// storeMentions saves the mentions in text against targetId and returns the ids of the users
+// mentioned.
+func (s *ApiServer) storeMentions(ctx context.Context, target store.MentionTarget, targetId int, text string) ([]int, error) {
+	mentions, err := s.resolveMentions(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Mentions.SetMentions(ctx, target, targetId, mentions); err != nil {
+		return nil, err
+	}
+	userIds := make([]int, len(mentions))
+	for i, m := range mentions {
+		userIds[i] = m.UserId
+	}
+	return userIds, nil
+}
+
+// notifyMentioned sends notification to each of userIds other than its actor. Users are
+// notified at most once per post or comment, however often it is edited.
+func (s *ApiServer) notifyMentioned(ctx context.Context, userIds []int, notification store.NewNotification) error {
+	for _, userId := range userIds {
+		if userId == notification.ActorId {
+			continue
+		}
+		notification.UserId = userId
+		if err := s.store.Notifications.Create(ctx, notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -5,7 +5,6 @@ import (
 	"github.com/cappstr/GopherSocial/internal/store"
 	"log/slog"
 	"net/http"
-	"strconv"
 	"strings"
 )
 
@@ -21,8 +20,10 @@ func LoggingMiddleware(logger *slog.Logger) func(next http.Handler) http.Handler
 func AuthMiddleware(jwtManager *JwtManager, userStore *store.UsersStore) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if strings.HasPrefix(r.URL.Path, "/v1/auth/") {
+			// Media downloads are authorized by the signature in their URL instead.
+			if strings.HasPrefix(r.URL.Path, "/v1/auth/") || strings.HasPrefix(r.URL.Path, "/v1/files/") {
 				next.ServeHTTP(w, r)
+				return
 			}
 			var token string
 			authHeader := r.Header.Get("Authorization")
@@ -46,16 +47,12 @@ func AuthMiddleware(jwtManager *JwtManager, userStore *store.UsersStore) func(ne
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
-			userIdStr, err := parsedToken.Claims.GetSubject()
-			if err != nil {
-				w.WriteHeader(http.StatusUnauthorized)
-			}
-			userId, err := strconv.Atoi(userIdStr)
+			userId, err := parsedToken.Claims.GetSubject()
 			if err != nil {
 				w.WriteHeader(http.StatusUnauthorized)
 				return
 			}
-			user, err := userStore.GetUserById(r.Context(), userId)
+			user, err := userStore.GetUserByPublicId(r.Context(), userId)
 			if err != nil {
 				slog.Error("failed to get user", "userId", userId, "err", err)
 				w.WriteHeader(http.StatusUnauthorized)
@@ -65,3 +62,9 @@ func AuthMiddleware(jwtManager *JwtManager, userStore *store.UsersStore) func(ne
 		})
 	}
 }
+
+// userFromContext returns the user AuthMiddleware stored in ctx, or nil if there is none.
+func userFromContext(ctx context.Context) *store.User {
+	user, _ := ctx.Value("user").(*store.User)
+	return user
+}
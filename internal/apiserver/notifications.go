@@ -0,0 +1,57 @@
+package apiserver
+
+import (
+	"github.com/cappstr/GopherSocial/internal/store"
+	"log/slog"
+	"net/http"
+)
+
+type MarkReadRequest struct {
+	Ids []string `json:"ids"`
+}
+
+func (req MarkReadRequest) Validate() error {
+	return nil
+}
+
+// ListNotificationsHandler lists the signed-in user's notifications, only unread ones when the
+// "unread" query parameter is "true".
+func (s *ApiServer) ListNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+	notifications, next, err := s.store.Notifications.List(r.Context(), userFromContext(r.Context()).Id, unreadOnly, page)
+	if err != nil {
+		slog.Error("failed to list notifications", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.Notification]{
+		Data:       &notifications,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// MarkNotificationsReadHandler marks the notifications listed in the request as read, or all of
+// them when the list is empty.
+func (s *ApiServer) MarkNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := Decode[MarkReadRequest](r)
+	if err != nil {
+		slog.Error("failed to decode request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := s.store.Notifications.MarkRead(r.Context(), userFromContext(r.Context()).Id, req.Ids); err != nil {
+		slog.Error("failed to mark notifications read", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
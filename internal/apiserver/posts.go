@@ -1,16 +1,46 @@
 package apiserver
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"github.com/cappstr/GopherSocial/internal/entities"
+	"github.com/cappstr/GopherSocial/internal/markdown"
+	"github.com/cappstr/GopherSocial/internal/store"
 	"log/slog"
 	"net/http"
+	"slices"
+	"time"
 )
 
 type PostRequest struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Language string `json:"language,omitempty"`
+	// Format is "plain" or "markdown". New posts default to plain.
+	Format string `json:"format,omitempty"`
+	// Status is "draft", "scheduled" or "published". New posts default to published.
+	Status    string     `json:"status,omitempty"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	// Visibility is "public", "followers" or "mentioned". New posts default to public.
+	Visibility string `json:"visibility,omitempty"`
+	// MediaIds are the ids of uploads to attach, in order. On update, leaving it out keeps the
+	// current attachments and an empty list removes them.
+	MediaIds []string `json:"media_ids,omitempty"`
+	// QuoteId is the id of a published post to quote. It is only read when creating a post.
+	QuoteId string `json:"quote_id,omitempty"`
 }
 
+const (
+	// maxPostMedia is how many uploads can be attached to one post.
+	maxPostMedia = 4
+	// maxPostLinks is how many of the links in a post get a preview.
+	maxPostLinks = 3
+	// maxPostContent is the longest a post's content can be, in bytes.
+	maxPostContent = 40_000
+)
+
 func (req PostRequest) Validate() error {
 	if req.Title == "" {
 		return errors.New("title is required")
@@ -18,6 +48,40 @@ func (req PostRequest) Validate() error {
 	if req.Content == "" {
 		return errors.New("content is required")
 	}
+	if len(req.Content) > maxPostContent {
+		return fmt.Errorf("content must be at most %d bytes", maxPostContent)
+	}
+	if req.Language != "" && !store.IsSearchLanguage(req.Language) {
+		return errors.New("language is not supported")
+	}
+	if req.Format != "" && req.Format != store.FormatPlain && req.Format != store.FormatMarkdown {
+		return errors.New("format must be plain or markdown")
+	}
+	switch req.Visibility {
+	case "", store.VisibilityPublic, store.VisibilityFollowers, store.VisibilityMentioned:
+	default:
+		return errors.New("visibility must be public, followers or mentioned")
+	}
+	switch req.Status {
+	case "", store.PostDraft, store.PostPublished:
+		if req.PublishAt != nil {
+			return errors.New("publish_at is only allowed for scheduled posts")
+		}
+	case store.PostScheduled:
+		if req.PublishAt == nil || !req.PublishAt.After(time.Now()) {
+			return errors.New("scheduled posts need a publish_at in the future")
+		}
+	default:
+		return errors.New("status must be draft, scheduled or published")
+	}
+	if len(req.MediaIds) > maxPostMedia {
+		return fmt.Errorf("at most %d media can be attached", maxPostMedia)
+	}
+	for i, id := range req.MediaIds {
+		if slices.Contains(req.MediaIds[:i], id) {
+			return errors.New("media_ids must not repeat")
+		}
+	}
 	return nil
 }
 
@@ -26,17 +90,508 @@ func (s *ApiServer) CreatePostHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		slog.Error("failed to decode request", "err", err)
 		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	language := req.Language
+	if language == "" {
+		language = s.config.SearchLanguage
+	}
+	format := req.Format
+	if format == "" {
+		format = store.FormatPlain
+	}
+	status := req.Status
+	if status == "" {
+		status = store.PostPublished
+	}
+	visibility := req.Visibility
+	if visibility == "" {
+		visibility = store.VisibilityPublic
+	}
+	var quoteOf *int
+	if req.QuoteId != "" {
+		quoted, err := s.store.Posts.GetPostByPublicId(r.Context(), req.QuoteId, userFromContext(r.Context()).Id)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "the quoted post is unavailable", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			slog.Error("failed to get quoted post", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if quoted.Status != store.PostPublished {
+			http.Error(w, "the quoted post is unavailable", http.StatusBadRequest)
+			return
+		}
+		quoteOf = &quoted.Id
+	}
+	post, err := s.store.Posts.CreatePost(r.Context(), store.NewPost{
+		Title:       req.Title,
+		Content:     req.Content,
+		Language:    language,
+		Format:      format,
+		ContentHTML: renderContent(format, req.Content),
+		Status:      status,
+		Visibility:  visibility,
+		PublishAt:   req.PublishAt,
+		QuoteOf:     quoteOf,
+		MediaIds:    req.MediaIds,
+	})
+	if errors.Is(err, store.ErrMediaUnavailable) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	_, err = s.store.Posts.CreatePost(r.Context(), req.Title, req.Content)
 	if err != nil {
 		slog.Error("failed to create post", "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.extractEntities(r.Context(), post); err != nil {
+		slog.Error("failed to extract post entities", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if post.Status == store.PostPublished {
+		s.deliverPost(post.Id)
+	}
+	if err := s.hydratePosts(r.Context(), post); err != nil {
+		slog.Error("failed to hydrate post", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
-	if err := Encode[ApiResponse[struct{}]](ApiResponse[struct{}]{
+	if err := Encode[ApiResponse[store.Posts]](ApiResponse[store.Posts]{
+		Data:    post,
 		Message: "successfully created post",
 	}, w, http.StatusCreated); err != nil {
 		slog.Error("failed to encode response", "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
+
+// renderContent returns the HTML rendering of Markdown content, which is stored with the post so
+// that reads don't render it again, or nil for plain text.
+func renderContent(format, content string) *string {
+	if format != store.FormatMarkdown {
+		return nil
+	}
+	html := markdown.Render(content)
+	return &html
+}
+
+// postFromPath loads the post named by the "id" path value. It writes the error response and
+// returns false when the post can't be loaded.
+func (s *ApiServer) postFromPath(w http.ResponseWriter, r *http.Request) (*store.Posts, bool) {
+	post, err := s.store.Posts.GetPostByPublicId(r.Context(), r.PathValue("id"), userFromContext(r.Context()).Id)
+	return post, postLoaded(w, err)
+}
+
+// anyPostFromPath is postFromPath for moderators, who may act on posts they can't see.
+func (s *ApiServer) anyPostFromPath(w http.ResponseWriter, r *http.Request) (*store.Posts, bool) {
+	post, err := s.store.Posts.GetAnyPostByPublicId(r.Context(), r.PathValue("id"))
+	return post, postLoaded(w, err)
+}
+
+// postLoaded writes the error response for err from loading a post and reports whether it was
+// loaded.
+func postLoaded(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		slog.Error("failed to get post", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+func (s *ApiServer) GetPostHandler(w http.ResponseWriter, r *http.Request) {
+	post, ok := s.postFromPath(w, r)
+	if !ok {
+		return
+	}
+	if err := s.hydratePosts(r.Context(), post); err != nil {
+		slog.Error("failed to hydrate post", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[store.Posts]{Data: post}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// extractEntities stores the hashtags, links and @mentions parsed out of the content of post. The
+// previews of the links are queued once the post is published.
+func (s *ApiServer) extractEntities(ctx context.Context, post *store.Posts) error {
+	if err := s.store.Tags.SetPostTags(ctx, post.Id, entities.Hashtags(post.Content)); err != nil {
+		return err
+	}
+	urls := entities.URLs(post.Content)
+	urls = urls[:min(len(urls), maxPostLinks)]
+	if err := s.store.LinkPreviews.SetPostLinks(ctx, post.Id, urls); err != nil {
+		return err
+	}
+	_, err := s.storeMentions(ctx, store.PostMentions, post.Id, post.Content)
+	return err
+}
+
+func mentionNotification(post *store.Posts) store.NewNotification {
+	return store.NewNotification{
+		ActorId: post.UserId,
+		Kind:    store.NotificationMention,
+		PostId:  &post.Id,
+	}
+}
+
+// notifyPublished notifies the users mentioned in the published post, and the author of the post
+// it quotes. Users already notified of the post aren't notified again.
+func (s *ApiServer) notifyPublished(ctx context.Context, post *store.Posts) error {
+	mentions, err := s.store.Mentions.ForTargets(ctx, store.PostMentions, []int{post.Id})
+	if err != nil {
+		return err
+	}
+	mentioned := make([]int, len(mentions[post.Id]))
+	for i, m := range mentions[post.Id] {
+		mentioned[i] = m.UserId
+	}
+	if err := s.notifyMentioned(ctx, mentioned, mentionNotification(post)); err != nil {
+		return err
+	}
+	return s.notifyQuoted(ctx, post)
+}
+
+func (s *ApiServer) UpdatePostHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := Decode[PostRequest](r)
+	if err != nil {
+		slog.Error("failed to decode request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	post, ok := s.postFromPath(w, r)
+	if !ok {
+		return
+	}
+	if post.UserId != userFromContext(r.Context()).Id {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	language := req.Language
+	if language == "" {
+		language = post.Language
+	}
+	format := req.Format
+	if format == "" {
+		format = post.Format
+	}
+	status := req.Status
+	if status == "" {
+		status = post.Status
+	}
+	visibility := req.Visibility
+	if visibility == "" {
+		visibility = post.Visibility
+	}
+	if status == store.PostScheduled && req.PublishAt == nil {
+		http.Error(w, "scheduled posts need a publish_at in the future", http.StatusBadRequest)
+		return
+	}
+	if post.Status == store.PostPublished && status != store.PostPublished {
+		http.Error(w, "published posts cannot be unpublished", http.StatusConflict)
+		return
+	}
+	if !s.editable(post) {
+		http.Error(w, "the edit window of this post has closed", http.StatusForbidden)
+		return
+	}
+	wasPublished, wasVisibility := post.Status == store.PostPublished, post.Visibility
+	post, err = s.store.Posts.UpdatePost(r.Context(), post.Id, store.NewPost{
+		Title:       req.Title,
+		Content:     req.Content,
+		Language:    language,
+		Format:      format,
+		ContentHTML: renderContent(format, req.Content),
+		Status:      status,
+		Visibility:  visibility,
+		PublishAt:   req.PublishAt,
+		MediaIds:    req.MediaIds,
+	})
+	if errors.Is(err, store.ErrMediaUnavailable) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to update post", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.extractEntities(r.Context(), post); err != nil {
+		slog.Error("failed to extract post entities", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// UpdatePost queued a delivery for a post becoming published, and for a change of visibility,
+	// where fanning out again reaches the followers a wider visibility now lets see the post.
+	// Other edits of published posts only notify the users they newly mention and preview the
+	// links they add.
+	if post.Status == store.PostPublished && (!wasPublished || post.Visibility != wasVisibility) {
+		s.deliverPost(post.Id)
+	} else if post.Status == store.PostPublished {
+		if err := s.notifyPublished(r.Context(), post); err != nil {
+			slog.Error("failed to notify mentioned users", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err := s.queueLinkPreviews(r.Context(), post.Id); err != nil {
+			slog.Error("failed to queue link previews", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := s.hydratePosts(r.Context(), post); err != nil {
+		slog.Error("failed to hydrate post", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[store.Posts]{
+		Data:    post,
+		Message: "successfully updated post",
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// editable reports whether post is still within the configured edit window. Drafts and scheduled
+// posts can always be edited.
+func (s *ApiServer) editable(post *store.Posts) bool {
+	if post.Status != store.PostPublished || s.config.PostEditWindow == 0 {
+		return true
+	}
+	return time.Since(post.CreatedAt) <= s.config.PostEditWindow
+}
+
+// ListRevisionsHandler lists the earlier versions of a post, newest first.
+func (s *ApiServer) ListRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	post, ok := s.postFromPath(w, r)
+	if !ok {
+		return
+	}
+	revisions, next, err := s.store.Posts.ListRevisions(r.Context(), post.Id, page)
+	if err != nil {
+		slog.Error("failed to list post revisions", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.PostRevision]{
+		Data:       &revisions,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (s *ApiServer) DeletePostHandler(w http.ResponseWriter, r *http.Request) {
+	user := userFromContext(r.Context())
+	loadPost := s.postFromPath
+	if user.IsModerator() {
+		loadPost = s.anyPostFromPath
+	}
+	post, ok := loadPost(w, r)
+	if !ok {
+		return
+	}
+	if post.UserId != user.Id && !user.IsModerator() {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if err := s.store.Posts.DeletePost(r.Context(), post.Id, user.Id); err != nil {
+		slog.Error("failed to delete post", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestorePostHandler takes a post the signed-in user deleted back out of their trash.
+func (s *ApiServer) RestorePostHandler(w http.ResponseWriter, r *http.Request) {
+	post, err := s.store.Posts.RestorePost(r.Context(), r.PathValue("id"), userFromContext(r.Context()).Id,
+		s.config.TrashRetention)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to restore post", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydratePosts(r.Context(), post); err != nil {
+		slog.Error("failed to hydrate post", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[store.Posts]{
+		Data:    post,
+		Message: "successfully restored post",
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// ListTrashHandler lists the posts the signed-in user deleted and can still restore.
+func (s *ApiServer) ListTrashHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	posts, next, err := s.store.Posts.ListTrash(r.Context(), userFromContext(r.Context()).Id,
+		s.config.TrashRetention, page)
+	if err != nil {
+		slog.Error("failed to list trash", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydratePosts(r.Context(), postPtrs(posts)...); err != nil {
+		slog.Error("failed to hydrate posts", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.Posts]{
+		Data:       &posts,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// ListDraftsHandler lists the signed-in user's draft and scheduled posts.
+func (s *ApiServer) ListDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	posts, next, err := s.store.Posts.ListDrafts(r.Context(), userFromContext(r.Context()).Id, page)
+	if err != nil {
+		slog.Error("failed to list drafts", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydratePosts(r.Context(), postPtrs(posts)...); err != nil {
+		slog.Error("failed to hydrate posts", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.Posts]{
+		Data:       &posts,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (s *ApiServer) FeedHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	posts, next, err := s.store.Posts.ListPosts(r.Context(), userFromContext(r.Context()).Id, page)
+	if err != nil {
+		slog.Error("failed to list posts", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydratePosts(r.Context(), postPtrs(posts)...); err != nil {
+		slog.Error("failed to hydrate posts", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.Posts]{
+		Data:       &posts,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (s *ApiServer) UserPostsHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, ok := s.userFromPath(w, r)
+	if !ok {
+		return
+	}
+	posts, next, err := s.store.Posts.ListPostsByUser(r.Context(), user.Id, userFromContext(r.Context()).Id, page)
+	if err != nil {
+		slog.Error("failed to list posts", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydratePosts(r.Context(), postPtrs(posts)...); err != nil {
+		slog.Error("failed to hydrate posts", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.Posts]{
+		Data:       &posts,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (s *ApiServer) TimelineHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	posts, next, err := s.store.Timelines.ListHomeTimeline(r.Context(), userFromContext(r.Context()).Id,
+		s.config.FanoutMaxFollowers, page)
+	if err != nil {
+		slog.Error("failed to list timeline", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydratePosts(r.Context(), postPtrs(posts)...); err != nil {
+		slog.Error("failed to hydrate posts", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.Posts]{
+		Data:       &posts,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
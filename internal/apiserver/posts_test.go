@@ -0,0 +1,35 @@
+package apiserver
+
+import (
+	"github.com/cappstr/GopherSocial/internal/config"
+	"github.com/cappstr/GopherSocial/internal/store"
+	"testing"
+	"time"
+)
+
+func TestEditable(t *testing.T) {
+	s := &ApiServer{config: &config.Config{PostEditWindow: time.Hour}}
+	tests := []struct {
+		name   string
+		status string
+		age    time.Duration
+		want   bool
+	}{
+		{name: "within the window", status: store.PostPublished, age: 59 * time.Minute, want: true},
+		{name: "past the window", status: store.PostPublished, age: 61 * time.Minute, want: false},
+		{name: "old draft", status: store.PostDraft, age: 24 * time.Hour, want: true},
+		{name: "old scheduled post", status: store.PostScheduled, age: 24 * time.Hour, want: true},
+	}
+	for _, tt := range tests {
+		post := &store.Posts{Status: tt.status, CreatedAt: time.Now().Add(-tt.age)}
+		if got := s.editable(post); got != tt.want {
+			t.Errorf("%s: editable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Without a window, published posts can always be edited.
+	s.config.PostEditWindow = 0
+	if !s.editable(&store.Posts{Status: store.PostPublished, CreatedAt: time.Now().Add(-24 * time.Hour)}) {
+		t.Error("post not editable without an edit window")
+	}
+}
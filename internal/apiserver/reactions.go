@@ -0,0 +1,96 @@
+package apiserver
+
+import (
+	"github.com/cappstr/GopherSocial/internal/store"
+	"log/slog"
+	"net/http"
+)
+
+// reactionTargetFromPath resolves the post, and the comment when the route has one, that a
+// reaction request refers to. It writes the error response and returns false on failure.
+func (s *ApiServer) reactionTargetFromPath(w http.ResponseWriter, r *http.Request) (store.ReactionTarget, int, bool) {
+	post, ok := s.postFromPath(w, r)
+	if !ok {
+		return store.ReactionTarget{}, 0, false
+	}
+	if r.PathValue("commentId") == "" {
+		return store.PostReactions, post.Id, true
+	}
+	comment, ok := s.commentFromPath(w, r, post)
+	if !ok {
+		return store.ReactionTarget{}, 0, false
+	}
+	return store.CommentReactions, comment.Id, true
+}
+
+func (s *ApiServer) AddReactionHandler(w http.ResponseWriter, r *http.Request) {
+	kind := r.PathValue("kind")
+	if !store.IsReactionKind(kind) {
+		http.Error(w, "unknown reaction", http.StatusBadRequest)
+		return
+	}
+	target, targetId, ok := s.reactionTargetFromPath(w, r)
+	if !ok {
+		return
+	}
+	user := userFromContext(r.Context())
+	if err := s.store.Reactions.AddReaction(r.Context(), target, targetId, user.Id, kind); err != nil {
+		slog.Error("failed to add reaction", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *ApiServer) RemoveReactionHandler(w http.ResponseWriter, r *http.Request) {
+	kind := r.PathValue("kind")
+	if !store.IsReactionKind(kind) {
+		http.Error(w, "unknown reaction", http.StatusBadRequest)
+		return
+	}
+	target, targetId, ok := s.reactionTargetFromPath(w, r)
+	if !ok {
+		return
+	}
+	user := userFromContext(r.Context())
+	if err := s.store.Reactions.RemoveReaction(r.Context(), target, targetId, user.Id, kind); err != nil {
+		slog.Error("failed to remove reaction", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListReactionsHandler lists who reacted, optionally only with the reaction in the "kind" query
+// parameter.
+func (s *ApiServer) ListReactionsHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	kind := r.URL.Query().Get("kind")
+	if kind != "" && !store.IsReactionKind(kind) {
+		http.Error(w, "unknown reaction", http.StatusBadRequest)
+		return
+	}
+	target, targetId, ok := s.reactionTargetFromPath(w, r)
+	if !ok {
+		return
+	}
+	reactors, next, err := s.store.Reactions.ListReactors(r.Context(), target, targetId, kind,
+		userFromContext(r.Context()).Id, page)
+	if err != nil {
+		slog.Error("failed to list reactions", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.Reactor]{
+		Data:       &reactors,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
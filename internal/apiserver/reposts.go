@@ -0,0 +1,130 @@
+package apiserver
+
+import (
+	"context"
+	"github.com/cappstr/GopherSocial/internal/store"
+	"log/slog"
+	"net/http"
+)
+
+// RepostHandler reposts a published, public post of an account that isn't private into the
+// timelines of the signed-in user's followers, notifying its author the first time. Reposts of
+// posts that later stop being visible to everyone are only shown to the users who can still
+// see the post.
+func (s *ApiServer) RepostHandler(w http.ResponseWriter, r *http.Request) {
+	post, ok := s.postFromPath(w, r)
+	if !ok {
+		return
+	}
+	if post.Status != store.PostPublished {
+		http.Error(w, "only published posts can be reposted", http.StatusConflict)
+		return
+	}
+	if post.Visibility != store.VisibilityPublic {
+		http.Error(w, "only public posts can be reposted", http.StatusConflict)
+		return
+	}
+	author, err := s.store.User.GetUserById(r.Context(), post.UserId)
+	if err != nil {
+		slog.Error("failed to get post author", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if author.Private {
+		http.Error(w, "posts of private accounts can't be reposted", http.StatusConflict)
+		return
+	}
+	user := userFromContext(r.Context())
+	repostId, err := s.store.Reposts.Repost(r.Context(), user.Id, post.Id)
+	if err != nil {
+		slog.Error("failed to repost", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	status, message := http.StatusCreated, "successfully reposted post"
+	if repostId != 0 {
+		s.deliverRepost(repostId)
+		if post.UserId != user.Id {
+			if err := s.store.Notifications.Create(r.Context(), store.NewNotification{
+				UserId:  post.UserId,
+				ActorId: user.Id,
+				Kind:    store.NotificationRepost,
+				PostId:  &post.Id,
+			}); err != nil {
+				slog.Error("failed to notify reposted author", "err", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+	} else {
+		status, message = http.StatusOK, "already reposted post"
+	}
+	if err := Encode(ApiResponse[struct{}]{Message: message}, w, status); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// UndoRepostHandler takes back the signed-in user's repost of a post, removing it from the
+// timelines it was fanned out to.
+func (s *ApiServer) UndoRepostHandler(w http.ResponseWriter, r *http.Request) {
+	post, ok := s.postFromPath(w, r)
+	if !ok {
+		return
+	}
+	if err := s.store.Reposts.Undo(r.Context(), userFromContext(r.Context()).Id, post.Id); err != nil {
+		slog.Error("failed to undo repost", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListRepostsHandler lists who reposted a post.
+func (s *ApiServer) ListRepostsHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	post, ok := s.postFromPath(w, r)
+	if !ok {
+		return
+	}
+	reposters, next, err := s.store.Reposts.ListReposters(r.Context(), post.Id, userFromContext(r.Context()).Id, page)
+	if err != nil {
+		slog.Error("failed to list reposts", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.Reposter]{
+		Data:       &reposters,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// notifyQuoted tells the author of the post quoted by post that it was quoted. Authors quoting
+// themselves aren't notified, and each quote notifies at most once.
+func (s *ApiServer) notifyQuoted(ctx context.Context, post *store.Posts) error {
+	if post.QuoteOf == nil {
+		return nil
+	}
+	quoted, err := s.store.Posts.GetPostById(ctx, *post.QuoteOf)
+	if err != nil {
+		return err
+	}
+	if quoted.UserId == post.UserId {
+		return nil
+	}
+	return s.store.Notifications.Create(ctx, store.NewNotification{
+		UserId:  quoted.UserId,
+		ActorId: post.UserId,
+		Kind:    store.NotificationQuote,
+		PostId:  &post.Id,
+	})
+}
@@ -0,0 +1,88 @@
+package apiserver
+
+import (
+	"github.com/cappstr/GopherSocial/internal/store"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxUserSearchResults keeps user search small enough to run on every keystroke.
+const maxUserSearchResults = 20
+
+// SearchPostsHandler runs the "q" query parameter as a web-search style query over post titles
+// and content, using the text search configuration in the "lang" query parameter if given.
+func (s *ApiServer) SearchPostsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	language := r.URL.Query().Get("lang")
+	if language == "" {
+		language = s.config.SearchLanguage
+	}
+	if !store.IsSearchLanguage(language) {
+		http.Error(w, "lang is not supported", http.StatusBadRequest)
+		return
+	}
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	results, next, err := s.store.Search.SearchPosts(r.Context(), q, language, userFromContext(r.Context()).Id, page)
+	if err != nil {
+		slog.Error("failed to search posts", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	posts := make([]*store.Posts, len(results))
+	for i := range results {
+		posts[i] = &results[i].Posts
+	}
+	if err := s.hydratePosts(r.Context(), posts...); err != nil {
+		slog.Error("failed to hydrate posts", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.PostSearchResult]{
+		Data:       &results,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// SearchUsersHandler matches the "q" query parameter against usernames and display names, for
+// finding accounts and completing @mentions as they are typed.
+func (s *ApiServer) SearchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimPrefix(r.URL.Query().Get("q"), "@")
+	if q == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	limit := 10
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxUserSearchResults)
+	}
+	users, err := s.store.Search.SearchUsers(r.Context(), q, userFromContext(r.Context()).Id, limit)
+	if err != nil {
+		slog.Error("failed to search users", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.UserSearchResult]{Data: &users}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
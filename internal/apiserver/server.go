@@ -3,8 +3,11 @@ package apiserver
 import (
 	"context"
 	"errors"
+	"github.com/cappstr/GopherSocial/internal/blob"
 	"github.com/cappstr/GopherSocial/internal/config"
+	"github.com/cappstr/GopherSocial/internal/linkpreview"
 	"github.com/cappstr/GopherSocial/internal/store"
+	"github.com/cappstr/GopherSocial/internal/worker"
 	"log/slog"
 	"net"
 	"net/http"
@@ -17,14 +20,23 @@ type ApiServer struct {
 	logger     *slog.Logger
 	store      *store.Store
 	jwtManager *JwtManager
+	workers    *worker.Pool
+	blobs      blob.BlobStore
+	previews   *linkpreview.Fetcher
+	cursors    *store.CursorCodec
 }
 
-func New(config *config.Config, logger *slog.Logger, store *store.Store, jwtManager *JwtManager) *ApiServer {
+func New(config *config.Config, logger *slog.Logger, store *store.Store, jwtManager *JwtManager,
+	workers *worker.Pool, blobs blob.BlobStore, previews *linkpreview.Fetcher, cursors *store.CursorCodec) *ApiServer {
 	return &ApiServer{
 		config:     config,
 		logger:     logger,
 		store:      store,
 		jwtManager: jwtManager,
+		workers:    workers,
+		blobs:      blobs,
+		previews:   previews,
+		cursors:    cursors,
 	}
 }
 
@@ -34,6 +46,64 @@ func (s *ApiServer) Start(ctx context.Context) error {
 	mux.HandleFunc("POST /v1/auth/signup", s.SignUpHandler)
 	mux.HandleFunc("POST /v1/auth/signin", s.SignInHandler)
 	mux.HandleFunc("POST /v1/post", s.CreatePostHandler)
+	mux.HandleFunc("GET /v1/posts/{id}", s.GetPostHandler)
+	mux.HandleFunc("PATCH /v1/posts/{id}", s.UpdatePostHandler)
+	mux.HandleFunc("DELETE /v1/posts/{id}", s.DeletePostHandler)
+	mux.HandleFunc("POST /v1/posts/{id}/restore", s.RestorePostHandler)
+	mux.HandleFunc("GET /v1/posts/{id}/revisions", s.ListRevisionsHandler)
+	mux.HandleFunc("POST /v1/posts/{id}/comments", s.CreateCommentHandler)
+	mux.HandleFunc("GET /v1/posts/{id}/comments", s.ListCommentsHandler)
+	mux.HandleFunc("GET /v1/posts/{id}/comments/{commentId}/replies", s.ListRepliesHandler)
+	mux.HandleFunc("GET /v1/posts/{id}/comments/{commentId}/thread", s.ThreadHandler)
+	mux.HandleFunc("PATCH /v1/posts/{id}/comments/{commentId}", s.UpdateCommentHandler)
+	mux.HandleFunc("DELETE /v1/posts/{id}/comments/{commentId}", s.DeleteCommentHandler)
+	mux.HandleFunc("POST /v1/posts/{id}/comments/{commentId}/restore", s.RestoreCommentHandler)
+	mux.HandleFunc("POST /v1/posts/{id}/repost", s.RepostHandler)
+	mux.HandleFunc("DELETE /v1/posts/{id}/repost", s.UndoRepostHandler)
+	mux.HandleFunc("GET /v1/posts/{id}/reposts", s.ListRepostsHandler)
+	mux.HandleFunc("PUT /v1/posts/{id}/bookmark", s.BookmarkHandler)
+	mux.HandleFunc("DELETE /v1/posts/{id}/bookmark", s.RemoveBookmarkHandler)
+	mux.HandleFunc("GET /v1/posts/{id}/reactions", s.ListReactionsHandler)
+	mux.HandleFunc("PUT /v1/posts/{id}/reactions/{kind}", s.AddReactionHandler)
+	mux.HandleFunc("DELETE /v1/posts/{id}/reactions/{kind}", s.RemoveReactionHandler)
+	mux.HandleFunc("GET /v1/posts/{id}/comments/{commentId}/reactions", s.ListReactionsHandler)
+	mux.HandleFunc("PUT /v1/posts/{id}/comments/{commentId}/reactions/{kind}", s.AddReactionHandler)
+	mux.HandleFunc("DELETE /v1/posts/{id}/comments/{commentId}/reactions/{kind}", s.RemoveReactionHandler)
+	mux.HandleFunc("GET /v1/drafts", s.ListDraftsHandler)
+	mux.HandleFunc("GET /v1/bookmarks", s.ListBookmarksHandler)
+	mux.HandleFunc("POST /v1/bookmarks/collections", s.CreateCollectionHandler)
+	mux.HandleFunc("GET /v1/bookmarks/collections", s.ListCollectionsHandler)
+	mux.HandleFunc("PATCH /v1/bookmarks/collections/{id}", s.RenameCollectionHandler)
+	mux.HandleFunc("DELETE /v1/bookmarks/collections/{id}", s.DeleteCollectionHandler)
+	mux.HandleFunc("POST /v1/media", s.UploadMediaHandler)
+	mux.HandleFunc("GET /v1/files/{id}", s.DownloadMediaHandler)
+	mux.HandleFunc("GET /v1/files/{id}/{thumbnail}", s.DownloadMediaHandler)
+	mux.HandleFunc("GET /v1/trash", s.ListTrashHandler)
+	mux.HandleFunc("GET /v1/feed", s.FeedHandler)
+	mux.HandleFunc("GET /v1/timeline", s.TimelineHandler)
+	mux.HandleFunc("GET /v1/search/posts", s.SearchPostsHandler)
+	mux.HandleFunc("GET /v1/search/users", s.SearchUsersHandler)
+	mux.HandleFunc("GET /v1/tags/trending", s.TrendingTagsHandler)
+	mux.HandleFunc("GET /v1/notifications", s.ListNotificationsHandler)
+	mux.HandleFunc("POST /v1/notifications/read", s.MarkNotificationsReadHandler)
+	mux.HandleFunc("GET /v1/tags/{tag}/posts", s.TagPostsHandler)
+	mux.HandleFunc("PATCH /v1/users/me", s.UpdateProfileHandler)
+	mux.HandleFunc("GET /v1/users/{username}", s.GetProfileHandler)
+	mux.HandleFunc("GET /v1/users/{username}/posts", s.UserPostsHandler)
+	mux.HandleFunc("POST /v1/users/{username}/follow", s.FollowHandler)
+	mux.HandleFunc("DELETE /v1/users/{username}/follow", s.UnfollowHandler)
+	mux.HandleFunc("GET /v1/users/{username}/followers", s.ListFollowersHandler)
+	mux.HandleFunc("GET /v1/users/{username}/following", s.ListFollowingHandler)
+	mux.HandleFunc("POST /v1/users/{username}/block", s.BlockHandler)
+	mux.HandleFunc("DELETE /v1/users/{username}/block", s.UnblockHandler)
+	mux.HandleFunc("POST /v1/users/{username}/mute", s.MuteHandler)
+	mux.HandleFunc("DELETE /v1/users/{username}/mute", s.UnmuteHandler)
+	mux.HandleFunc("GET /v1/blocks", s.ListBlocksHandler)
+	mux.HandleFunc("GET /v1/mutes", s.ListMutesHandler)
+	mux.HandleFunc("GET /v1/follow-requests", s.ListFollowRequestsHandler)
+	mux.HandleFunc("GET /v1/follow-requests/sent", s.ListSentFollowRequestsHandler)
+	mux.HandleFunc("POST /v1/follow-requests/{username}/approve", s.ApproveFollowRequestHandler)
+	mux.HandleFunc("POST /v1/follow-requests/{username}/reject", s.RejectFollowRequestHandler)
 
 	loggingMiddleware := LoggingMiddleware(s.logger)
 	authMiddleware := AuthMiddleware(s.jwtManager, s.store.User)
@@ -42,6 +112,8 @@ func (s *ApiServer) Start(ctx context.Context) error {
 		Handler: loggingMiddleware(authMiddleware(mux)),
 	}
 
+	s.startScheduledJobs(ctx)
+
 	go func() {
 		s.logger.Info("API server started", "listening on:", net.JoinHostPort(s.config.ApiServerHost,
 			s.config.ApiServerAddr))
@@ -0,0 +1,60 @@
+package apiserver
+
+import (
+	"github.com/cappstr/GopherSocial/internal/entities"
+	"github.com/cappstr/GopherSocial/internal/store"
+	"log/slog"
+	"net/http"
+)
+
+func (s *ApiServer) TagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tag := entities.NormalizeHashtag(r.PathValue("tag"))
+	posts, next, err := s.store.Tags.ListPostsByTag(r.Context(), tag, userFromContext(r.Context()).Id, page)
+	if err != nil {
+		slog.Error("failed to list posts by tag", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.hydratePosts(r.Context(), postPtrs(posts)...); err != nil {
+		slog.Error("failed to hydrate posts", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.Posts]{
+		Data:       &posts,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// TrendingTagsHandler lists the trending tags of the window in the "window" query parameter,
+// one of the keys of trendingWindows, defaulting to the last 24 hours.
+func (s *ApiServer) TrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = "24h"
+	}
+	if _, ok := trendingWindows[window]; !ok {
+		http.Error(w, "unknown window", http.StatusBadRequest)
+		return
+	}
+	tags, err := s.store.Tags.ListTrending(r.Context(), window)
+	if err != nil {
+		slog.Error("failed to list trending tags", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.TrendingTag]{Data: &tags}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -1,8 +1,10 @@
 package apiserver
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"github.com/cappstr/GopherSocial/internal/store"
 	"log/slog"
 	"net/http"
 )
@@ -95,7 +97,7 @@ func (s *ApiServer) SignInHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusUnauthorized)
 	}
 
-	tokenPair, err := s.jwtManager.GenerateTokenPair(user.Id)
+	tokenPair, err := s.jwtManager.GenerateTokenPair(user.PublicId)
 	if err != nil {
 		slog.Error("failed to generate token pair", "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -124,3 +126,366 @@ func (s *ApiServer) SignInHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
+
+// userFromPath loads the user named by the "username" path value. Users who blocked the
+// signed-in user are reported as not found. It writes the error response and returns false when
+// the user can't be loaded.
+func (s *ApiServer) userFromPath(w http.ResponseWriter, r *http.Request) (*store.User, bool) {
+	user, ok := s.anyUserFromPath(w, r)
+	if !ok {
+		return nil, false
+	}
+	blocked, err := s.store.Blocks.IsBlocking(r.Context(), user.Id, userFromContext(r.Context()).Id)
+	if err != nil {
+		slog.Error("failed to check block", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	if blocked {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	return user, true
+}
+
+// anyUserFromPath is userFromPath for the routes by which the signed-in user stops interacting
+// with a user, such as blocking or unfollowing them, which work even if that user blocked them.
+func (s *ApiServer) anyUserFromPath(w http.ResponseWriter, r *http.Request) (*store.User, bool) {
+	user, err := s.store.User.GetUserByUsername(r.Context(), r.PathValue("username"))
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		slog.Error("failed to get user", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return user, true
+}
+
+// UpdateProfileRequest changes the fields that are set, leaving out the others.
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name,omitempty"`
+	// Private makes following the account take an approved follow request.
+	Private *bool `json:"private,omitempty"`
+}
+
+func (req UpdateProfileRequest) Validate() error {
+	if req.DisplayName != nil && len(*req.DisplayName) > 255 {
+		return errors.New("display_name must be at most 255 bytes")
+	}
+	return nil
+}
+
+func (s *ApiServer) UpdateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := Decode[UpdateProfileRequest](r)
+	if err != nil {
+		slog.Error("failed to decode request", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	wasPrivate := userFromContext(r.Context()).Private
+	user, err := s.store.User.UpdateProfile(r.Context(), userFromContext(r.Context()).Id, req.DisplayName, req.Private)
+	if err != nil {
+		slog.Error("failed to update profile", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// An account that is no longer private lets in everyone still waiting to follow it.
+	if wasPrivate && !user.Private {
+		followerIds, err := s.store.Follows.ApproveAllRequests(r.Context(), user.Id)
+		if err != nil {
+			slog.Error("failed to approve follow requests", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		for _, followerId := range followerIds {
+			s.backfillTimeline(followerId, user.Id)
+		}
+	}
+	// Reposts by others of the posts of an account that becomes private leave the timelines of
+	// those who don't follow it.
+	if !wasPrivate && user.Private {
+		if err := s.store.Timelines.RemoveHiddenReposts(r.Context(), user.Id); err != nil {
+			slog.Error("failed to remove hidden reposts", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := Encode(ApiResponse[store.User]{
+		Data:    user,
+		Message: "successfully updated profile",
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+type ProfileResponse struct {
+	*store.User
+	Following bool `json:"following"`
+	// Requested reports whether the viewer's request to follow the private account is pending.
+	Requested bool `json:"requested"`
+	Blocked   bool `json:"blocked"`
+	Muted     bool `json:"muted"`
+}
+
+func (s *ApiServer) GetProfileHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.userFromPath(w, r)
+	if !ok {
+		return
+	}
+	following, err := s.store.Follows.IsFollowing(r.Context(), userFromContext(r.Context()).Id, user.Id)
+	if err != nil {
+		slog.Error("failed to check follow", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	requested, err := s.store.Follows.HasRequested(r.Context(), userFromContext(r.Context()).Id, user.Id)
+	if err != nil {
+		slog.Error("failed to check follow request", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	blocked, err := s.store.Blocks.IsBlocking(r.Context(), userFromContext(r.Context()).Id, user.Id)
+	if err != nil {
+		slog.Error("failed to check block", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	muted, err := s.store.Blocks.IsMuting(r.Context(), userFromContext(r.Context()).Id, user.Id)
+	if err != nil {
+		slog.Error("failed to check mute", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[ProfileResponse]{
+		Data: &ProfileResponse{User: user, Following: following, Requested: requested, Blocked: blocked, Muted: muted},
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// FollowHandler follows a user, or asks to when the account is private. Users can't follow
+// someone they blocked or who blocked them.
+func (s *ApiServer) FollowHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.userFromPath(w, r)
+	if !ok {
+		return
+	}
+	follower := userFromContext(r.Context())
+	if user.Private {
+		s.requestFollow(w, r, follower, user)
+		return
+	}
+	created, err := s.store.Follows.Follow(r.Context(), follower.Id, user.Id)
+	if errors.Is(err, store.ErrSelfFollow) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, store.ErrBlocked) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to follow user", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	status, message := http.StatusCreated, "successfully followed user"
+	if created {
+		s.backfillTimeline(follower.Id, user.Id)
+	} else {
+		status, message = http.StatusOK, "already following user"
+	}
+	if err := Encode(ApiResponse[struct{}]{Message: message}, w, status); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// requestFollow asks the private account user to let follower follow it, unless follower
+// already does.
+func (s *ApiServer) requestFollow(w http.ResponseWriter, r *http.Request, follower, user *store.User) {
+	following, err := s.store.Follows.IsFollowing(r.Context(), follower.Id, user.Id)
+	if err != nil {
+		slog.Error("failed to check follow", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	status, message := http.StatusOK, "already following user"
+	if !following {
+		created, err := s.store.Follows.RequestFollow(r.Context(), follower.Id, user.Id)
+		if errors.Is(err, store.ErrSelfFollow) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, store.ErrBlocked) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			slog.Error("failed to request follow", "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		status, message = http.StatusOK, "follow request already pending"
+		if created {
+			if err := s.store.Notifications.Create(r.Context(), store.NewNotification{
+				UserId:  user.Id,
+				ActorId: follower.Id,
+				Kind:    store.NotificationFollowRequest,
+			}); err != nil {
+				slog.Error("failed to notify follow request", "err", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			status, message = http.StatusAccepted, "follow request sent"
+		}
+	}
+	if err := Encode(ApiResponse[struct{}]{Message: message}, w, status); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// UnfollowHandler stops following a user, or withdraws the pending request to follow them.
+func (s *ApiServer) UnfollowHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.anyUserFromPath(w, r)
+	if !ok {
+		return
+	}
+	follower := userFromContext(r.Context())
+	if _, err := s.store.Follows.Unfollow(r.Context(), follower.Id, user.Id); err != nil {
+		slog.Error("failed to unfollow user", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if _, err := s.store.Follows.DeleteRequest(r.Context(), follower.Id, user.Id); err != nil {
+		slog.Error("failed to withdraw follow request", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.store.Timelines.RemoveAuthor(r.Context(), follower.Id, user.Id); err != nil {
+		slog.Error("failed to remove author from timeline", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *ApiServer) ListFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	s.listFollows(w, r, s.store.Follows.ListFollowers)
+}
+
+func (s *ApiServer) ListFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	s.listFollows(w, r, s.store.Follows.ListFollowing)
+}
+
+func (s *ApiServer) listFollows(w http.ResponseWriter, r *http.Request,
+	list func(ctx context.Context, userId int, page store.Page) ([]store.FollowEntry, *store.Cursor, error)) {
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, ok := s.userFromPath(w, r)
+	if !ok {
+		return
+	}
+	entries, next, err := list(r.Context(), user.Id, page)
+	if err != nil {
+		slog.Error("failed to list follows", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.FollowEntry]{
+		Data:       &entries,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// ListFollowRequestsHandler lists the users waiting for the signed-in user to approve their
+// follow requests.
+func (s *ApiServer) ListFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	s.listFollowRequests(w, r, s.store.Follows.ListRequests)
+}
+
+// ListSentFollowRequestsHandler lists the private accounts the signed-in user asked to follow
+// and that haven't answered yet.
+func (s *ApiServer) ListSentFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	s.listFollowRequests(w, r, s.store.Follows.ListSentRequests)
+}
+
+func (s *ApiServer) listFollowRequests(w http.ResponseWriter, r *http.Request,
+	list func(ctx context.Context, userId int, page store.Page) ([]store.FollowRequest, *store.Cursor, error)) {
+	page, err := s.pageFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	requests, next, err := list(r.Context(), userFromContext(r.Context()).Id, page)
+	if err != nil {
+		slog.Error("failed to list follow requests", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := Encode(ApiResponse[[]store.FollowRequest]{
+		Data:       &requests,
+		NextCursor: s.encodeCursor(next),
+	}, w, http.StatusOK); err != nil {
+		slog.Error("failed to encode response", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// ApproveFollowRequestHandler lets the user named in the path follow the signed-in user.
+func (s *ApiServer) ApproveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	follower, ok := s.userFromPath(w, r)
+	if !ok {
+		return
+	}
+	user := userFromContext(r.Context())
+	approved, err := s.store.Follows.ApproveRequest(r.Context(), follower.Id, user.Id)
+	if err != nil {
+		slog.Error("failed to approve follow request", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !approved {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.backfillTimeline(follower.Id, user.Id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RejectFollowRequestHandler turns down the request of the user named in the path to follow
+// the signed-in user.
+func (s *ApiServer) RejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	follower, ok := s.anyUserFromPath(w, r)
+	if !ok {
+		return
+	}
+	rejected, err := s.store.Follows.DeleteRequest(r.Context(), follower.Id, userFromContext(r.Context()).Id)
+	if err != nil {
+		slog.Error("failed to reject follow request", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !rejected {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
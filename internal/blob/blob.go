@@ -0,0 +1,42 @@
+// Package blob stores uploaded files behind a common interface, on the local filesystem or in an
+// S3-compatible object store.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cappstr/GopherSocial/internal/config"
+	"io"
+)
+
+// ErrNotFound is returned by Get when there is no blob under the key.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore stores blobs of bytes under string keys. Keys are slash-separated relative paths.
+type BlobStore interface {
+	// Put stores size bytes read from body under key, replacing any blob already there.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get opens the blob stored under key. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBlobStore returns the blob store selected by cfg.MediaStorage.
+func NewBlobStore(cfg *config.Config) (BlobStore, error) {
+	switch cfg.MediaStorage {
+	case "local":
+		return NewLocalStore(cfg.MediaDir)
+	case "s3":
+		return NewS3Store(S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown media storage %q", cfg.MediaStorage)
+	}
+}
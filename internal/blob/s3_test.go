@@ -0,0 +1,156 @@
+package blob
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// s3StandIn is an in-memory stand-in for an S3 bucket that checks the signature of each request
+// against what it actually received.
+type s3StandIn struct {
+	opts    S3Options
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.validSignature(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(data)) != r.ContentLength {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[r.URL.Path] = data
+	case http.MethodGet:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// validSignature recomputes the Signature Version 4 of r from the path and headers received.
+func (f *s3StandIn) validSignature(r *http.Request) bool {
+	amzDate := r.Header.Get("X-Amz-Date")
+	signer := &S3Store{opts: f.opts}
+	req, err := http.NewRequest(r.Method, "http://"+r.Host+r.RequestURI, nil)
+	if err != nil {
+		return false
+	}
+	now, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil {
+		return false
+	}
+	signer.sign(req, now)
+	return req.Header.Get("Authorization") == r.Header.Get("Authorization")
+}
+
+func newS3StandIn(t *testing.T) (*S3Store, *s3StandIn) {
+	standIn := &s3StandIn{objects: make(map[string][]byte)}
+	server := httptest.NewServer(standIn)
+	t.Cleanup(server.Close)
+	standIn.opts = S3Options{
+		Endpoint:  server.URL,
+		Region:    "us-east-1",
+		Bucket:    "media",
+		AccessKey: "access",
+		SecretKey: "secret",
+	}
+	store, err := NewS3Store(standIn.opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, standIn
+}
+
+func testBlobStore(t *testing.T, store BlobStore, key string) {
+	ctx := context.Background()
+	content := "blob content"
+	if err := store.Put(ctx, key, strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	body, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(got) != content {
+		t.Fatalf("Get = %q, want %q", got, content)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete of a missing blob: %v", err)
+	}
+}
+
+func TestS3StoreAgainstStandIn(t *testing.T) {
+	store, standIn := newS3StandIn(t)
+	testBlobStore(t, store, "01HZY3/thumb small+1.jpg")
+	if len(standIn.objects) != 0 {
+		t.Fatalf("stand-in still holds %d objects", len(standIn.objects))
+	}
+}
+
+func TestS3StoreRejectedSignature(t *testing.T) {
+	store, _ := newS3StandIn(t)
+	store.opts.SecretKey = "wrong"
+	err := store.Put(context.Background(), "key", strings.NewReader("x"), 1, "text/plain")
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Put with a wrong secret error = %v, want a 403", err)
+	}
+}
+
+// TestS3StoreAgainstMinio runs against the MinIO of docker-compose.yml, or any S3 service named
+// by S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY. The bucket must exist.
+func TestS3StoreAgainstMinio(t *testing.T) {
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_ENDPOINT is not set")
+	}
+	store, err := NewS3Store(S3Options{
+		Endpoint:  endpoint,
+		Region:    "us-east-1",
+		Bucket:    os.Getenv("S3_BUCKET"),
+		AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_SECRET_KEY"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	suffix := make([]byte, 8)
+	rand.Read(suffix)
+	testBlobStore(t, store, "test/"+hex.EncodeToString(suffix))
+}
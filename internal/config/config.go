@@ -1,8 +1,10 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"github.com/caarlos0/env/v11"
+	"time"
 )
 
 type ENV string
@@ -23,6 +25,59 @@ type Config struct {
 	Env              ENV    `env:"ENV" envDefault:"prod"`
 	DatabaseTestPort string `env:"DB_TEST_PORT"`
 	JwtSecret        string `env:"JWT_SECRET"`
+	// CursorSecret encrypts pagination cursors. It must be at least minSecretLength bytes.
+	CursorSecret    string `env:"CURSOR_SECRET"`
+	DefaultPageSize int    `env:"DEFAULT_PAGE_SIZE" envDefault:"20"`
+	MaxPageSize     int    `env:"MAX_PAGE_SIZE" envDefault:"100"`
+	Workers         int    `env:"WORKERS" envDefault:"4"`
+	WorkerQueueSize int    `env:"WORKER_QUEUE_SIZE" envDefault:"1024"`
+	// FanoutMaxFollowers is the follower count above which posts are merged into home
+	// timelines at read time instead of being fanned out on write.
+	FanoutMaxFollowers int `env:"FANOUT_MAX_FOLLOWERS" envDefault:"10000"`
+	// SearchLanguage is the text search configuration for posts and queries that don't name one.
+	SearchLanguage string `env:"SEARCH_LANGUAGE" envDefault:"english"`
+	// TrendingInterval is how often trending tags are recomputed.
+	TrendingInterval time.Duration `env:"TRENDING_INTERVAL" envDefault:"5m"`
+	// SchedulerInterval is how often scheduled posts are checked for publishing.
+	SchedulerInterval time.Duration `env:"SCHEDULER_INTERVAL" envDefault:"30s"`
+	// PostEditWindow is how long after publishing a post can still be edited. Zero means forever.
+	PostEditWindow time.Duration `env:"POST_EDIT_WINDOW" envDefault:"0"`
+	// TrashRetention is how long deleted posts and comments are kept before they are purged.
+	TrashRetention time.Duration `env:"TRASH_RETENTION" envDefault:"720h"`
+	// PurgeInterval is how often expired posts and comments are purged.
+	PurgeInterval time.Duration `env:"PURGE_INTERVAL" envDefault:"1h"`
+	// MediaStorage selects where uploads are kept: "local" for MediaDir or "s3" for S3Bucket.
+	MediaStorage string `env:"MEDIA_STORAGE" envDefault:"local"`
+	MediaDir     string `env:"MEDIA_DIR" envDefault:"data/media"`
+	S3Endpoint   string `env:"S3_ENDPOINT"`
+	S3Region     string `env:"S3_REGION" envDefault:"us-east-1"`
+	S3Bucket     string `env:"S3_BUCKET"`
+	S3AccessKey  string `env:"S3_ACCESS_KEY"`
+	S3SecretKey  string `env:"S3_SECRET_KEY"`
+	// MediaMaxBytes is the largest upload accepted.
+	MediaMaxBytes int64 `env:"MEDIA_MAX_BYTES" envDefault:"10485760"`
+	// MediaUrlSecret signs media download URLs, which are valid for MediaUrlTTL. It must be at
+	// least minSecretLength bytes.
+	MediaUrlSecret string        `env:"MEDIA_URL_SECRET"`
+	MediaUrlTTL    time.Duration `env:"MEDIA_URL_TTL" envDefault:"1h"`
+	// MediaSweepInterval is how often uploads whose processing was lost are queued again.
+	MediaSweepInterval time.Duration `env:"MEDIA_SWEEP_INTERVAL" envDefault:"1m"`
+	// DeliverySweepInterval is how often deliveries of posts and reposts whose job was lost are
+	// queued again.
+	DeliverySweepInterval time.Duration `env:"DELIVERY_SWEEP_INTERVAL" envDefault:"1m"`
+	// LinkPreviewSweepInterval is how often link previews whose fetch was lost are queued again.
+	LinkPreviewSweepInterval time.Duration `env:"LINK_PREVIEW_SWEEP_INTERVAL" envDefault:"1m"`
+	// LinkPreviewTimeout bounds fetching one page for a link preview, of which at most
+	// LinkPreviewMaxBytes are read. Previews are fetched again after LinkPreviewTTL.
+	LinkPreviewTimeout      time.Duration `env:"LINK_PREVIEW_TIMEOUT" envDefault:"5s"`
+	LinkPreviewMaxBytes     int64         `env:"LINK_PREVIEW_MAX_BYTES" envDefault:"1048576"`
+	LinkPreviewMaxRedirects int           `env:"LINK_PREVIEW_MAX_REDIRECTS" envDefault:"5"`
+	LinkPreviewTTL          time.Duration `env:"LINK_PREVIEW_TTL" envDefault:"24h"`
+}
+
+// IsDev reports whether the config points at the development database.
+func (c *Config) IsDev() bool {
+	return c.Env == dev
 }
 
 func (c *Config) DatabaseUrl() string {
@@ -39,5 +94,27 @@ func NewConfig() (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error parsing config: %w", err)
 	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
 	return &cfg, nil
 }
+
+// minSecretLength is the shortest secret accepted for keying cursors and signing URLs.
+const minSecretLength = 32
+
+func (c *Config) validate() error {
+	if len(c.CursorSecret) < minSecretLength {
+		return fmt.Errorf("CURSOR_SECRET must be at least %d bytes", minSecretLength)
+	}
+	if len(c.MediaUrlSecret) < minSecretLength {
+		return fmt.Errorf("MEDIA_URL_SECRET must be at least %d bytes", minSecretLength)
+	}
+	if c.DefaultPageSize <= 0 {
+		return errors.New("DEFAULT_PAGE_SIZE must be positive")
+	}
+	if c.MaxPageSize < c.DefaultPageSize {
+		return errors.New("MAX_PAGE_SIZE must be at least DEFAULT_PAGE_SIZE")
+	}
+	return nil
+}
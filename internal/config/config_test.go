@@ -0,0 +1,56 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewConfigRequiresSecrets(t *testing.T) {
+	secret := strings.Repeat("s", minSecretLength)
+	for _, tc := range []struct {
+		cursorSecret, mediaUrlSecret, wantErr string
+	}{
+		{cursorSecret: "", mediaUrlSecret: secret, wantErr: "CURSOR_SECRET"},
+		{cursorSecret: "too short", mediaUrlSecret: secret, wantErr: "CURSOR_SECRET"},
+		{cursorSecret: secret, mediaUrlSecret: "", wantErr: "MEDIA_URL_SECRET"},
+		{cursorSecret: secret, mediaUrlSecret: "too short", wantErr: "MEDIA_URL_SECRET"},
+		{cursorSecret: secret, mediaUrlSecret: secret},
+	} {
+		t.Setenv("CURSOR_SECRET", tc.cursorSecret)
+		t.Setenv("MEDIA_URL_SECRET", tc.mediaUrlSecret)
+		_, err := NewConfig()
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("NewConfig: %v", err)
+		}
+		if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+			t.Errorf("NewConfig with cursor secret %q and media secret %q: error = %v, want %s error",
+				tc.cursorSecret, tc.mediaUrlSecret, err, tc.wantErr)
+		}
+	}
+}
+
+func TestNewConfigChecksPageSizes(t *testing.T) {
+	secret := strings.Repeat("s", minSecretLength)
+	t.Setenv("CURSOR_SECRET", secret)
+	t.Setenv("MEDIA_URL_SECRET", secret)
+	for _, tc := range []struct {
+		defaultSize, maxSize, wantErr string
+	}{
+		{defaultSize: "0", maxSize: "100", wantErr: "DEFAULT_PAGE_SIZE"},
+		{defaultSize: "-1", maxSize: "100", wantErr: "DEFAULT_PAGE_SIZE"},
+		{defaultSize: "20", maxSize: "0", wantErr: "MAX_PAGE_SIZE"},
+		{defaultSize: "50", maxSize: "20", wantErr: "MAX_PAGE_SIZE"},
+		{defaultSize: "20", maxSize: "20"},
+	} {
+		t.Setenv("DEFAULT_PAGE_SIZE", tc.defaultSize)
+		t.Setenv("MAX_PAGE_SIZE", tc.maxSize)
+		_, err := NewConfig()
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("NewConfig: %v", err)
+		}
+		if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+			t.Errorf("NewConfig with default page size %s and max %s: error = %v, want %s error",
+				tc.defaultSize, tc.maxSize, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package entities
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MaxHashtagLength matches the width of tags.name.
+const MaxHashtagLength = 100
+
+// hashtagPattern matches a # that doesn't follow a word character, URL fragment or another
+// hashtag, followed by letters, digits and underscores including at least one letter, so
+// "#42" is not a tag.
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&/#])#([\p{L}\p{N}_]*[\p{L}_][\p{L}\p{N}_]*)`)
+
+// Hashtags returns the distinct hashtags in text, lowercased and without the leading #, in the
+// order they first appear.
+func Hashtags(text string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, match := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+		tag := strings.ToLower(match[1])
+		if len([]rune(tag)) > MaxHashtagLength || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// NormalizeHashtag lowercases tag and strips a leading #, for looking up tags named in URLs.
+func NormalizeHashtag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
@@ -0,0 +1,29 @@
+package entities
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHashtags(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"#go and #Rust", []string{"go", "rust"}},
+		{"#Go #go #GO", []string{"go"}},
+		{"#42 #2024 #v2 #_", []string{"v2", "_"}},
+		{"a#b email#tag", nil},
+		{"https://x.com/page#section &#39; ##double", nil},
+		{"(#paren) #end. #comma, #bang!", []string{"paren", "end", "comma", "bang"}},
+		{"#café #日本", []string{"café", "日本"}},
+		{"#" + strings.Repeat("a", MaxHashtagLength+1) + " #ok", []string{"ok"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := Hashtags(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("Hashtags(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
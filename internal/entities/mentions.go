@@ -0,0 +1,32 @@
+package entities
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// mentionPattern matches an @ that doesn't follow a word character, email local part or URL
+// path, followed by a username.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@/.])(@([\p{L}\p{N}_]+))`)
+
+// Mention is an @username in a piece of text. Start and End are character (not byte) offsets
+// of the mention including its @, with End exclusive.
+type Mention struct {
+	Username string
+	Start    int
+	End      int
+}
+
+// Mentions returns the @mentions in text in the order they appear.
+func Mentions(text string) []Mention {
+	var mentions []Mention
+	for _, match := range mentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		start := utf8.RuneCountInString(text[:match[2]])
+		mentions = append(mentions, Mention{
+			Username: text[match[4]:match[5]],
+			Start:    start,
+			End:      start + utf8.RuneCountInString(text[match[2]:match[3]]),
+		})
+	}
+	return mentions
+}
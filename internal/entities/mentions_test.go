@@ -0,0 +1,25 @@
+package entities
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		text string
+		want []Mention
+	}{
+		{"@alice hi", []Mention{{"alice", 0, 6}}},
+		{"hi @bob, and (@carol).", []Mention{{"bob", 3, 7}, {"carol", 14, 20}}},
+		{"née @zoë", []Mention{{"zoë", 4, 8}}},
+		{"me@example.com a@b https://x.com/@dave", nil},
+		{"@@eve .@frank", nil},
+		{"@ alone", nil},
+	}
+	for _, tt := range tests {
+		if got := Mentions(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("Mentions(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+}
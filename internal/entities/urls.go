@@ -0,0 +1,37 @@
+package entities
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MaxURLLength bounds the URLs returned by URLs; longer ones are skipped.
+const MaxURLLength = 2048
+
+var urlPattern = regexp.MustCompile("(?i)\\bhttps?://[^\\s<>\"'`]+")
+
+// URLs returns the distinct http and https URLs in text in the order they first appear.
+// Punctuation ending a sentence, and closing parentheses without a matching opening one, such
+// as those of Markdown links, are not taken as part of the URL.
+func URLs(text string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, u := range urlPattern.FindAllString(text, -1) {
+		for {
+			trimmed := strings.TrimRight(u, ".,;:!?*_~")
+			if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, "(") < strings.Count(trimmed, ")") {
+				trimmed = trimmed[:len(trimmed)-1]
+			}
+			if trimmed == u {
+				break
+			}
+			u = trimmed
+		}
+		if len(u) > MaxURLLength || seen[u] {
+			continue
+		}
+		seen[u] = true
+		urls = append(urls, u)
+	}
+	return urls
+}
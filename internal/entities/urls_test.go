@@ -0,0 +1,29 @@
+package entities
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestURLs(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"see https://go.dev and HTTP://x.com/a?b=c#d", []string{"https://go.dev", "HTTP://x.com/a?b=c#d"}},
+		{"https://go.dev. https://x.com, https://y.com?! https://z.com/*a*", []string{
+			"https://go.dev", "https://x.com", "https://y.com", "https://z.com/*a",
+		}},
+		{"(https://x.com/a) [b](https://y.com/c)", []string{"https://x.com/a", "https://y.com/c"}},
+		{"https://en.wikipedia.org/wiki/Go_(language)).", []string{"https://en.wikipedia.org/wiki/Go_(language)"}},
+		{`<a href="https://x.com">https://x.com</a>`, []string{"https://x.com"}},
+		{"ftp://x.com javascript:alert(1) xhttps://x.com", nil},
+		{"https://" + strings.Repeat("a", MaxURLLength) + " https://ok.com", []string{"https://ok.com"}},
+	}
+	for _, tt := range tests {
+		if got := URLs(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("URLs(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
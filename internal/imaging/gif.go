@@ -0,0 +1,66 @@
+package imaging
+
+import "errors"
+
+var errTruncatedGIF = errors.New("gif: truncated data")
+
+// gifFrames walks the blocks of the GIF in data without decoding any pixels, returning the number
+// of frames and their total area, so that animations too large to decode can be refused before
+// gif.DecodeAll allocates every frame.
+func gifFrames(data []byte) (frames, pixels int, err error) {
+	// Header and logical screen descriptor, then the global color table if there is one.
+	pos := 13
+	if len(data) < pos {
+		return 0, 0, errTruncatedGIF
+	}
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 << ((flags & 0x07) + 1)
+	}
+	for {
+		if pos >= len(data) {
+			return 0, 0, errTruncatedGIF
+		}
+		switch data[pos] {
+		case 0x21: // extension: label, then data sub-blocks
+			if pos, err = skipSubBlocks(data, pos+2); err != nil {
+				return 0, 0, err
+			}
+		case 0x2c: // image descriptor: position and size, flags, local color table, image data
+			if pos+10 > len(data) {
+				return 0, 0, errTruncatedGIF
+			}
+			width := int(data[pos+5]) | int(data[pos+6])<<8
+			height := int(data[pos+7]) | int(data[pos+8])<<8
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << ((flags & 0x07) + 1)
+			}
+			// Skip the LZW minimum code size.
+			if pos, err = skipSubBlocks(data, pos+1); err != nil {
+				return 0, 0, err
+			}
+			frames++
+			pixels += width * height
+		case 0x3b: // trailer
+			return frames, pixels, nil
+		default:
+			return 0, 0, errors.New("gif: unknown block")
+		}
+	}
+}
+
+// skipSubBlocks returns the position after the data sub-blocks starting at pos, which end with
+// an empty one.
+func skipSubBlocks(data []byte, pos int) (int, error) {
+	for {
+		if pos >= len(data) {
+			return 0, errTruncatedGIF
+		}
+		size := int(data[pos])
+		pos += 1 + size
+		if size == 0 {
+			return pos, nil
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Package imaging cleans up and derives thumbnails from uploaded images: JPEG, PNG, GIF and WebP.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	_ "golang.org/x/image/webp"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported image format")
+	ErrTooLarge    = errors.New("image has too many pixels")
+)
+
+const (
+	// MaxPixels bounds the decoded size of an image, which protects against small files that
+	// decompress into huge images. For animated GIFs, MaxFrames and MaxAnimationPixels bound
+	// the number of frames and their total size too, since every frame is decoded.
+	MaxPixels          = 40_000_000
+	MaxFrames          = 1_000
+	MaxAnimationPixels = 200_000_000
+	jpegQuality        = 85
+	// blurhashSize is the size images are shrunk to before computing their blurhash.
+	blurhashSize = 32
+)
+
+// Size is a thumbnail to generate, named Name, whose longer side is at most MaxDim pixels.
+type Size struct {
+	Name   string
+	MaxDim int
+}
+
+// Encoded is an encoded image and its dimensions.
+type Encoded struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+type Thumbnail struct {
+	Name string
+	Encoded
+}
+
+type Result struct {
+	// Image is the upload re-encoded, upright and without any metadata.
+	Image Encoded
+	// Thumbnails holds the sizes smaller than the image; larger ones are skipped.
+	Thumbnails []Thumbnail
+	Blurhash   string
+}
+
+// Process re-encodes the image in data, which drops EXIF, GPS and any other metadata, and
+// generates its thumbnails and blurhash. JPEGs are first rotated upright according to their
+// EXIF orientation, since it is lost with the rest. GIFs keep all their frames, but thumbnails
+// show only the first. WebP images are re-encoded as PNG, or as JPEG when they are opaque, since
+// there is no WebP encoder; animated WebP is unsupported.
+func Process(data []byte, sizes []Size) (*Result, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	var res Result
+	var frame image.Image
+	switch format {
+	case "jpeg", "png", "webp":
+		if frame, _, err = image.Decode(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+	case "gif":
+		frames, pixels, err := gifFrames(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+		if frames > MaxFrames || pixels > MaxAnimationPixels {
+			return nil, ErrTooLarge
+		}
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := gif.EncodeAll(&buf, &gif.GIF{
+			Image:           g.Image,
+			Delay:           g.Delay,
+			Disposal:        g.Disposal,
+			LoopCount:       g.LoopCount,
+			Config:          g.Config,
+			BackgroundIndex: g.BackgroundIndex,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to encode image: %w", err)
+		}
+		res.Image = Encoded{Data: buf.Bytes(), ContentType: "image/gif", Width: cfg.Width, Height: cfg.Height}
+		frame = g.Image[0]
+	default:
+		return nil, ErrUnsupported
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, cfg.Width, cfg.Height))
+	draw.Draw(img, frame.Bounds(), frame, frame.Bounds().Min, draw.Src)
+	if format == "jpeg" {
+		img = orient(img, exifOrientation(data))
+	}
+	if format == "webp" {
+		format = "png"
+		if img.Opaque() {
+			format = "jpeg"
+		}
+	}
+	if format != "gif" {
+		if res.Image, err = encode(img, format); err != nil {
+			return nil, err
+		}
+	}
+
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	for _, size := range sizes {
+		if max(w, h) <= size.MaxDim {
+			continue
+		}
+		tw, th := fit(w, h, size.MaxDim)
+		thumb := resize(img, tw, th)
+		format := "png"
+		if thumb.Opaque() {
+			format = "jpeg"
+		}
+		enc, err := encode(thumb, format)
+		if err != nil {
+			return nil, err
+		}
+		res.Thumbnails = append(res.Thumbnails, Thumbnail{Name: size.Name, Encoded: enc})
+	}
+
+	small := img
+	if max(w, h) > blurhashSize {
+		bw, bh := fit(w, h, blurhashSize)
+		small = resize(img, bw, bh)
+	}
+	res.Blurhash = blurhash(small, 4, 3)
+	return &res, nil
+}
+
+func encode(img *image.RGBA, format string) (Encoded, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	case "png":
+		err = png.Encode(&buf, img)
+	default:
+		return Encoded{}, ErrUnsupported
+	}
+	if err != nil {
+		return Encoded{}, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return Encoded{
+		Data:        buf.Bytes(),
+		ContentType: "image/" + format,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}, nil
+}
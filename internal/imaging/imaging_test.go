@@ -0,0 +1,135 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+var sizes = []Size{{Name: "small", MaxDim: 16}, {Name: "large", MaxDim: 1000}}
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeGIF(t *testing.T, frames, w, h int) []byte {
+	t.Helper()
+	g := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, w, h), palette.Plan9))
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessPNG(t *testing.T) {
+	res, err := Process(encodePNG(t, 64, 32), sizes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Image.ContentType != "image/png" || res.Image.Width != 64 || res.Image.Height != 32 {
+		t.Fatalf("image = %s %dx%d, want image/png 64x32", res.Image.ContentType, res.Image.Width, res.Image.Height)
+	}
+	if len(res.Thumbnails) != 1 || res.Thumbnails[0].Name != "small" {
+		t.Fatalf("thumbnails = %+v, want only small", res.Thumbnails)
+	}
+	if thumb := res.Thumbnails[0]; thumb.Width != 16 || thumb.Height != 8 || thumb.ContentType != "image/jpeg" {
+		t.Fatalf("small thumbnail = %s %dx%d, want image/jpeg 16x8", thumb.ContentType, thumb.Width, thumb.Height)
+	}
+	if res.Blurhash == "" {
+		t.Fatal("no blurhash")
+	}
+}
+
+func TestProcessWebP(t *testing.T) {
+	for _, tc := range []struct {
+		name, data, want string
+	}{
+		// 1x1 images: a transparent lossless one, and an opaque lossy one.
+		{"lossless", "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA==", "image/png"},
+		{"lossy", "UklGRiIAAABXRUJQVlA4IBYAAAAwAQCdASoBAAEADsD+JaQAA3AAAAAA", "image/jpeg"},
+	} {
+		data, err := base64.StdEncoding.DecodeString(tc.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := Process(data, sizes)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if res.Image.ContentType != tc.want || res.Image.Width != 1 || res.Image.Height != 1 {
+			t.Fatalf("%s: image = %s %dx%d, want %s 1x1", tc.name, res.Image.ContentType,
+				res.Image.Width, res.Image.Height, tc.want)
+		}
+	}
+}
+
+func TestProcessAnimatedGIF(t *testing.T) {
+	res, err := Process(encodeGIF(t, 3, 32, 32), sizes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(res.Image.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != 3 {
+		t.Fatalf("processed GIF has %d frames, want 3", len(g.Image))
+	}
+}
+
+func TestProcessRefusesTooManyFrames(t *testing.T) {
+	_, err := Process(encodeGIF(t, MaxFrames+1, 1, 1), sizes)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("error = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestGIFFrames(t *testing.T) {
+	data := encodeGIF(t, 4, 30, 20)
+	frames, pixels, err := gifFrames(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames != 4 || pixels != 4*30*20 {
+		t.Fatalf("gifFrames = %d frames, %d pixels; want 4, %d", frames, pixels, 4*30*20)
+	}
+	if _, _, err := gifFrames(data[:len(data)/2]); err == nil {
+		t.Fatal("truncated GIF was accepted")
+	}
+}
+
+func TestProcessRefusesLargeAnimations(t *testing.T) {
+	// Thirteen 4000x4000 frames without any image data: each frame is under MaxPixels, but
+	// decoding them all would take more than MaxAnimationPixels.
+	data := []byte("GIF89a\xa0\x0f\xa0\x0f\x00\x00\x00")
+	for i := 0; i < 13; i++ {
+		data = append(data, 0x2c, 0, 0, 0, 0, 0xa0, 0x0f, 0xa0, 0x0f, 0x00, 0x02, 0x00)
+	}
+	data = append(data, 0x3b)
+	_, err := Process(data, sizes)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("error = %v, want ErrTooLarge", err)
+	}
+}
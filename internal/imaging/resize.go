@@ -0,0 +1,85 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// contribution is the span of source pixels, and their weights, averaged into one destination
+// pixel along one axis.
+type contribution struct {
+	start   int
+	weights []float32
+}
+
+// boxWeights spreads srcLen source pixels over dstLen destination pixels, weighting each source
+// pixel by how much of it falls within the destination pixel. It is only used to shrink.
+func boxWeights(srcLen, dstLen int) []contribution {
+	scale := float64(srcLen) / float64(dstLen)
+	out := make([]contribution, dstLen)
+	for i := range out {
+		lo := float64(i) * scale
+		hi := lo + scale
+		start, end := int(lo), min(int(math.Ceil(hi)), srcLen)
+		weights := make([]float32, end-start)
+		for j := start; j < end; j++ {
+			overlap := math.Min(hi, float64(j+1)) - math.Max(lo, float64(j))
+			weights[j-start] = float32(overlap / scale)
+		}
+		out[i] = contribution{start: start, weights: weights}
+	}
+	return out
+}
+
+// resize shrinks src to width by height by averaging the source pixels covering each
+// destination pixel, first along rows and then along columns. Averaging premultiplied pixels
+// keeps transparent pixels from bleeding their color into the result.
+func resize(src *image.RGBA, width, height int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	cols, rows := boxWeights(sw, width), boxWeights(sh, height)
+
+	tmp := make([]float32, width*sh*4)
+	for y := 0; y < sh; y++ {
+		row := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+		for x, c := range cols {
+			var px [4]float32
+			for k, w := range c.weights {
+				p := row[(c.start+k)*4:]
+				px[0] += w * float32(p[0])
+				px[1] += w * float32(p[1])
+				px[2] += w * float32(p[2])
+				px[3] += w * float32(p[3])
+			}
+			copy(tmp[(y*width+x)*4:], px[:])
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y, r := range rows {
+		for x := 0; x < width; x++ {
+			var px [4]float32
+			for k, w := range r.weights {
+				p := tmp[((r.start+k)*width+x)*4:]
+				px[0] += w * p[0]
+				px[1] += w * p[1]
+				px[2] += w * p[2]
+				px[3] += w * p[3]
+			}
+			o := dst.PixOffset(x, y)
+			for i := range px {
+				dst.Pix[o+i] = uint8(min(max(px[i]+0.5, 0), 255))
+			}
+		}
+	}
+	return dst
+}
+
+// fit returns the size of a w by h image scaled down to fit within maxDim on its longer side,
+// keeping its aspect ratio.
+func fit(w, h, maxDim int) (int, int) {
+	if w >= h {
+		return maxDim, max(1, int(math.Round(float64(h)*float64(maxDim)/float64(w))))
+	}
+	return max(1, int(math.Round(float64(w)*float64(maxDim)/float64(h)))), maxDim
+}
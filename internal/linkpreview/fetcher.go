@@ -0,0 +1,157 @@
+// Package linkpreview fetches the OpenGraph and Twitter card metadata of web pages linked from
+// posts.
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrDisallowedURL     = errors.New("url is not allowed")
+	ErrDisallowedAddress = errors.New("address is not allowed")
+	ErrNotHTML           = errors.New("page is not html")
+)
+
+// blockedPrefixes are the address ranges that aren't covered by the net.IP predicates used in
+// allowedAddr but still must not be reachable from the fetcher.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// allowedAddr reports whether addr is a public unicast address.
+func allowedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Options configures a Fetcher.
+type Options struct {
+	// Timeout bounds a whole fetch, redirects included.
+	Timeout time.Duration
+	// MaxBytes is how much of a page is read looking for metadata.
+	MaxBytes int64
+	// MaxRedirects is how many redirects are followed.
+	MaxRedirects int
+	// AllowPrivateNetworks lets the fetcher reach loopback and private addresses. It is only
+	// meant for tests against a local httptest server.
+	AllowPrivateNetworks bool
+}
+
+// Fetcher fetches link previews. To protect against server-side request forgery it only
+// connects to public addresses: the check runs on the address actually dialed, after DNS
+// resolution and on every redirect, so hostnames resolving to private addresses are refused
+// as well. Proxies from the environment are ignored, as they would bypass it.
+type Fetcher struct {
+	opts   Options
+	client *http.Client
+}
+
+func NewFetcher(opts Options) *Fetcher {
+	dialer := &net.Dialer{
+		Timeout: opts.Timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if opts.AllowPrivateNetworks {
+				return nil
+			}
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !allowedAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrDisallowedAddress, address)
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   opts.Timeout,
+		ResponseHeaderTimeout: opts.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", opts.MaxRedirects)
+			}
+			return checkURL(req.URL)
+		},
+	}
+	return &Fetcher{opts: opts, client: client}
+}
+
+func checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return fmt.Errorf("%w: %s", ErrDisallowedURL, u.Redacted())
+	}
+	return nil
+}
+
+// Fetch reads the page at rawURL and returns its preview.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Preview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDisallowedURL, err)
+	}
+	if err := checkURL(u); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "GopherSocialBot/1.0 (link preview)")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	res, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("page returned %s", res.Status)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); mediaType != "text/html" &&
+		mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("%w: %s", ErrNotHTML, mediaType)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, f.opts.MaxBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page: %w", err)
+	}
+	// Relative image URLs are resolved against the page they were found on, after redirects.
+	preview := parse(string(body), res.Request.URL)
+	if strings.TrimSpace(preview.Title) == "" {
+		return nil, errors.New("page has no title")
+	}
+	return preview, nil
+}
@@ -0,0 +1,87 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const page = `<html><head><title>Fallback</title>
+<meta property="og:title" content="Gophers">
+<meta property="og:image" content="/gopher.png">
+</head><body></body></html>`
+
+func newTestFetcher(allowPrivate bool) *Fetcher {
+	return NewFetcher(Options{
+		Timeout:              5 * time.Second,
+		MaxBytes:             1 << 20,
+		MaxRedirects:         2,
+		AllowPrivateNetworks: allowPrivate,
+	})
+}
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	}))
+	defer srv.Close()
+
+	preview, err := newTestFetcher(true).Fetch(context.Background(), srv.URL+"/post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Title != "Gophers" || preview.ImageURL != srv.URL+"/gopher.png" {
+		t.Fatalf("got %+v", preview)
+	}
+}
+
+func TestFetchRefusesPrivateAddresses(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(page))
+	}))
+	defer srv.Close()
+
+	_, err := newTestFetcher(false).Fetch(context.Background(), srv.URL)
+	if !errors.Is(err, ErrDisallowedAddress) {
+		t.Fatalf("got %v, want %v", err, ErrDisallowedAddress)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("server got %d requests", n)
+	}
+}
+
+func TestFetchStopsRedirects(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Redirect(w, r, "/again", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	if _, err := newTestFetcher(true).Fetch(context.Background(), srv.URL); err == nil {
+		t.Fatal("followed redirects without end")
+	}
+	// The first request and two redirects.
+	if n := requests.Load(); n != 3 {
+		t.Fatalf("server got %d requests, want 3", n)
+	}
+}
+
+func TestFetchRefusesRedirectsToOtherSchemes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	if _, err := newTestFetcher(true).Fetch(context.Background(), srv.URL); !errors.Is(err, ErrDisallowedURL) {
+		t.Fatalf("got %v, want %v", err, ErrDisallowedURL)
+	}
+}
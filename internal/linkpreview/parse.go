@@ -0,0 +1,92 @@
+package linkpreview
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+)
+
+// Preview is what a page says about itself in its metadata.
+type Preview struct {
+	Title       string
+	Description string
+	// ImageURL is an absolute http or https URL, or empty.
+	ImageURL string
+	SiteName string
+}
+
+var (
+	metaPattern  = regexp.MustCompile(`(?is)<meta\s([^>]*)>`)
+	attrPattern  = regexp.MustCompile(`(?is)([a-z:_-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	headEnd      = regexp.MustCompile(`(?i)</head\s*>`)
+)
+
+// parse extracts the preview from the page page, fetched from base. OpenGraph properties are
+// preferred, then Twitter card ones, then the page's title and description.
+func parse(page string, base *url.URL) *Preview {
+	if loc := headEnd.FindStringIndex(page); loc != nil {
+		page = page[:loc[0]]
+	}
+	page = strings.ToValidUTF8(page, "")
+	meta := make(map[string]string)
+	for _, m := range metaPattern.FindAllStringSubmatch(page, -1) {
+		attrs := make(map[string]string)
+		for _, a := range attrPattern.FindAllStringSubmatch(m[1], -1) {
+			attrs[strings.ToLower(a[1])] = a[2] + a[3] + a[4]
+		}
+		key := attrs["property"]
+		if key == "" {
+			key = attrs["name"]
+		}
+		key = strings.ToLower(key)
+		if _, seen := meta[key]; key != "" && !seen {
+			meta[key] = clean(attrs["content"])
+		}
+	}
+	first := func(keys ...string) string {
+		for _, k := range keys {
+			if v := meta[k]; v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	title := first("og:title", "twitter:title")
+	if title == "" {
+		if m := titlePattern.FindStringSubmatch(page); m != nil {
+			title = clean(m[1])
+		}
+	}
+	preview := &Preview{
+		Title:       truncate(title, maxTitleLength),
+		Description: truncate(first("og:description", "twitter:description", "description"), maxDescriptionLength),
+		SiteName:    truncate(first("og:site_name"), maxTitleLength),
+	}
+	if image := first("og:image:secure_url", "og:image", "og:image:url", "twitter:image", "twitter:image:src"); image != "" {
+		if u, err := base.Parse(image); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+			preview.ImageURL = u.String()
+		}
+	}
+	return preview
+}
+
+// clean unescapes HTML entities and collapses whitespace.
+func clean(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
@@ -0,0 +1,350 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"strings"
+)
+
+// linkRel is set on every link, as the targets are chosen by whoever wrote the post.
+const linkRel = "nofollow noopener noreferrer"
+
+// inline renders the inline content of a block: emphasis, code spans, links and text.
+//
+// Matching openers to closers by scanning ahead from each opener takes quadratic time on text
+// full of unclosed delimiters, so the text is scanned once up front instead. match holds, by
+// the position of an opener, where its construct ends: the end of the code span or autolink
+// opened by a ` or <, the ] closing a [, the ) closing a ( and, for a run of *, _ or ~, the
+// start of the next run that can close it. Zero means nothing matches. Rendering then walks
+// the text once, rendering the content of each construct in place.
+type inline struct {
+	b     *strings.Builder
+	s     string
+	match []int
+}
+
+func renderInline(b *strings.Builder, s string) {
+	in := &inline{b: b, s: s, match: make([]int, len(s))}
+	in.scan()
+	in.render(0, len(s))
+}
+
+// scan fills in match. Escapes, code spans and autolinks are skipped as they are found, so that
+// what they contain opens and closes nothing. Parentheses are matched in a separate pass that
+// skips escapes alone, since a link destination ends at its ) even inside backticks.
+func (in *inline) scan() {
+	s := in.s
+	codeSpans := newCodeSpans(s)
+	var brackets, runs []int
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			i += 2
+		case c == '`':
+			n := runLength(s, i)
+			if end := codeSpans.end(i, n); end > 0 {
+				in.match[i] = end
+				i = end
+			} else {
+				i += n
+			}
+		case c == '<':
+			if end := autolinkEnd(s, i); end > 0 {
+				in.match[i] = end
+				i = end
+			} else {
+				i++
+			}
+		case c == '[':
+			brackets = append(brackets, i)
+			i++
+		case c == ']':
+			if len(brackets) > 0 {
+				in.match[brackets[len(brackets)-1]] = i
+				brackets = brackets[:len(brackets)-1]
+			}
+			i++
+		case c == '*' || c == '_' || c == '~':
+			runs = append(runs, i)
+			i += runLength(s, i)
+		default:
+			i++
+		}
+	}
+
+	// Runs are linked to their closers from the last one back, keeping the nearest closer so far
+	// for each delimiter and length that can open emphasis.
+	var next [3][4]int
+	for k := len(runs) - 1; k >= 0; k-- {
+		i := runs[k]
+		c, n := strings.IndexByte("*_~", s[i]), runLength(s, i)
+		if n > 3 {
+			continue
+		}
+		in.match[i] = next[c][n]
+		if i > 0 && !isSpace(s[i-1]) && (s[i] != '_' || i+n >= len(s) || !isAlnum(s[i+n])) {
+			next[c][n] = i
+		}
+	}
+
+	var parens []int
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '(':
+			parens = append(parens, i)
+		case ')':
+			if len(parens) > 0 {
+				in.match[parens[len(parens)-1]] = i
+				parens = parens[:len(parens)-1]
+			}
+		}
+	}
+}
+
+// render renders s[lo:hi]. Constructs are only rendered when they end within it.
+func (in *inline) render(lo, hi int) {
+	s, text := in.s, lo
+	flush := func(end int) {
+		in.b.WriteString(html.EscapeString(s[text:end]))
+	}
+	for i := lo; i < hi; {
+		start := i
+		var next int
+		switch c := s[i]; {
+		case c == '\\' && i+1 < hi && isPunct(s[i+1]):
+			flush(i)
+			in.b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			next = i + 2
+		case c == '\x00':
+			flush(i)
+			in.b.WriteString("<br>\n")
+			next = i + 2
+		case c == '`':
+			next = in.renderCodeSpan(i, hi, func() { flush(start) })
+		case c == '*' || c == '_' || c == '~':
+			next = in.renderEmphasis(i, lo, hi, func() { flush(start) })
+		case c == '[' || c == '!' && i+1 < hi && s[i+1] == '[':
+			next = in.renderLink(i, hi, func() { flush(start) })
+		case c == '<':
+			next = in.renderAutolink(i, hi, func() { flush(start) })
+		}
+		if next == 0 {
+			i++
+			continue
+		}
+		i, text = next, next
+	}
+	flush(hi)
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\x00'
+}
+
+func isAlnum(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// runLength returns how many times s[i] repeats from i.
+func runLength(s string, i int) int {
+	n := 1
+	for i+n < len(s) && s[i+n] == s[i] {
+		n++
+	}
+	return n
+}
+
+// codeSpans finds where code spans end. A span opened by n backticks is closed by the next run
+// of exactly n.
+type codeSpans map[int][]int
+
+// newCodeSpans indexes the starts of the runs of backticks in s by their length.
+func newCodeSpans(s string) codeSpans {
+	spans := codeSpans{}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '`' {
+			n := runLength(s, i)
+			spans[n] = append(spans[n], i)
+			i += n - 1
+		}
+	}
+	return spans
+}
+
+// end returns the end of the code span opened by the n backticks at i, or 0 if they aren't
+// closed. The spans must be looked up in order, as the runs before i are dropped.
+func (spans codeSpans) end(i, n int) int {
+	starts := spans[n]
+	for len(starts) > 0 && starts[0] < i+n {
+		starts = starts[1:]
+	}
+	spans[n] = starts
+	if len(starts) == 0 {
+		return 0
+	}
+	return starts[0] + n
+}
+
+// renderCodeSpan renders the code span starting at i. Unclosed backticks are literal, including
+// any that follow.
+func (in *inline) renderCodeSpan(i, hi int, flush func()) int {
+	s, n, end := in.s, runLength(in.s, i), in.match[i]
+	if end == 0 || end > hi {
+		flush()
+		in.b.WriteString(strings.Repeat("`", n))
+		return i + n
+	}
+	code := strings.NewReplacer("\n", " ", "\x00", " ").Replace(s[i+n : end-n])
+	if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+		code = code[1 : len(code)-1]
+	}
+	flush()
+	in.b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+	return end
+}
+
+// renderEmphasis renders the emphasis opened by the run of delimiters at i: one * or _ for em,
+// two for strong, three for both and two ~ for del. A run that doesn't open emphasis, or isn't
+// closed, is written as text.
+func (in *inline) renderEmphasis(i, lo, hi int, flush func()) int {
+	s := in.s
+	c, n := s[i], runLength(s, i)
+	if c == '~' && n != 2 || n > 3 {
+		return in.skipRun(i, n, flush)
+	}
+	// An opener must be followed by text, and an underscore must not be inside a word, so
+	// that snake_case names stay as they are.
+	if i+n >= hi || isSpace(s[i+n]) || c == '_' && i > lo && isAlnum(s[i-1]) {
+		return in.skipRun(i, n, flush)
+	}
+	j := in.match[i]
+	if j == 0 || j+n > hi {
+		return in.skipRun(i, n, flush)
+	}
+	open, close := emphasisTags(c, n)
+	flush()
+	in.b.WriteString(open)
+	in.render(i+n, j)
+	in.b.WriteString(close)
+	return j + n
+}
+
+// skipRun writes a run of delimiters that doesn't open emphasis as text.
+func (in *inline) skipRun(i, n int, flush func()) int {
+	flush()
+	in.b.WriteString(html.EscapeString(in.s[i : i+n]))
+	return i + n
+}
+
+func emphasisTags(c byte, n int) (string, string) {
+	switch {
+	case c == '~':
+		return "<del>", "</del>"
+	case n == 1:
+		return "<em>", "</em>"
+	case n == 2:
+		return "<strong>", "</strong>"
+	default:
+		return "<em><strong>", "</strong></em>"
+	}
+}
+
+// renderLink renders the link, or image, [text](destination) starting at i. Images are
+// rendered as links to the image. Links to disallowed URLs are rendered as their text alone.
+// It returns 0 when there is no link at i.
+func (in *inline) renderLink(i, hi int, flush func()) int {
+	s, open := in.s, i
+	if s[i] == '!' {
+		open++
+	}
+	close := in.match[open]
+	if close == 0 || close+1 >= hi || s[close+1] != '(' {
+		return 0
+	}
+	end := in.match[close+1]
+	if end == 0 || end >= hi {
+		return 0
+	}
+	dest := strings.TrimSpace(s[close+2 : end])
+	if fields := strings.Fields(dest); len(fields) > 0 {
+		// Anything after the URL is a title, which is dropped.
+		dest = fields[0]
+	}
+	dest = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+	flush()
+	href, ok := safeURL(unescapeDest(dest))
+	if ok {
+		in.b.WriteString(`<a href="` + html.EscapeString(href) + `" rel="` + linkRel + `">`)
+	}
+	in.render(open+1, close)
+	if ok {
+		in.b.WriteString("</a>")
+	}
+	return end + 1
+}
+
+// unescapeDest removes backslash escapes from a link destination.
+func unescapeDest(dest string) string {
+	var b strings.Builder
+	for i := 0; i < len(dest); i++ {
+		if dest[i] == '\\' && i+1 < len(dest) && isPunct(dest[i+1]) {
+			i++
+		}
+		b.WriteByte(dest[i])
+	}
+	return b.String()
+}
+
+// autolinkEnd returns the end of the autolink <scheme:...> starting at i, or 0 if there is none.
+func autolinkEnd(s string, i int) int {
+	end := strings.IndexAny(s[i+1:], "<> \n\x00")
+	if end < 0 || s[i+1+end] != '>' {
+		return 0
+	}
+	if _, ok := safeURL(s[i+1 : i+1+end]); !ok {
+		return 0
+	}
+	return i + end + 2
+}
+
+// renderAutolink renders <scheme:...> as a link to itself. It returns 0 when there is no
+// autolink at i, in which case the < is escaped as text.
+func (in *inline) renderAutolink(i, hi int, flush func()) int {
+	end := in.match[i]
+	if end == 0 || end > hi {
+		return 0
+	}
+	target := in.s[i+1 : end-1]
+	href, _ := safeURL(target)
+	flush()
+	in.b.WriteString(`<a href="` + html.EscapeString(href) + `" rel="` + linkRel + `">` +
+		html.EscapeString(target) + "</a>")
+	return end
+}
+
+// safeURL returns the normalized form of raw if it is an absolute http, https or mailto URL.
+func safeURL(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if u.Host == "" {
+			return "", false
+		}
+	case "mailto":
+		if u.Opaque == "" {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	return u.String(), true
+}
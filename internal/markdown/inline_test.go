@@ -0,0 +1,61 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderInline(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"*a* **b** ***c*** ~~d~~", "<em>a</em> <strong>b</strong> <em><strong>c</strong></em> <del>d</del>"},
+		{"~e~ **a* * a*", "~e~ **a* * a*"},
+		{"_f_ snake_case_name", "<em>f</em> snake_case_name"},
+		{"**nested *em* here**", "<strong>nested <em>em</em> here</strong>"},
+		{`\*not em\*`, "*not em*"},
+		{"`code` ``a`b`` `unclosed", "<code>code</code> <code>a`b</code> `unclosed"},
+		{"*a `*` b*", "<em>a <code>*</code> b</em>"},
+		{"*a [b*](https://x) c*", "<em>a [b</em>](https://x) c*"},
+		{
+			"[a [b] c](https://x.com/(p)) [bad](javascript:alert(1))",
+			`<a href="https://x.com/(p)" rel="nofollow noopener noreferrer">a [b] c</a> bad`,
+		},
+		{"[`]`](https://x)", `<a href="https://x" rel="nofollow noopener noreferrer"><code>]</code></a>`},
+		{"![img](https://y.com/a.png)", `<a href="https://y.com/a.png" rel="nofollow noopener noreferrer">img</a>`},
+		{"<https://a*b>* <nope>", `<a href="https://a*b" rel="nofollow noopener noreferrer">https://a*b</a>* &lt;nope&gt;`},
+		{"a  \nb", "a<br>\nb"},
+	}
+	for _, tt := range tests {
+		want := "<p>" + tt.want + "</p>\n"
+		if got := Render(tt.src); got != want {
+			t.Errorf("Render(%q) = %q, want %q", tt.src, got, want)
+		}
+	}
+}
+
+// TestRenderInlineLinear checks that rendering text full of delimiters that are never closed
+// takes time in proportion to its length.
+func TestRenderInlineLinear(t *testing.T) {
+	for _, unit := range []string{"*a ", "_a ", "~~a ", "[a](", "[", "![", "(", "<a ", "`a ``b "} {
+		small := timeRender(strings.Repeat(unit, 2_000))
+		large := timeRender(strings.Repeat(unit, 32_000))
+		// 16 times the input should take about 16 times as long; quadratic matching takes
+		// 256 times as long.
+		if large > 64*small && large > 50*time.Millisecond {
+			t.Errorf("rendering %q repeated took %v for 2000 and %v for 32000 repeats", unit, small, large)
+		}
+	}
+}
+
+// timeRender returns the shortest of a few renderings of src, to keep noise out.
+func timeRender(src string) time.Duration {
+	best := time.Duration(1<<63 - 1)
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		Render(src)
+		best = min(best, time.Since(start))
+	}
+	return best
+}
@@ -0,0 +1,302 @@
+// Package markdown renders the Markdown of posts to HTML.
+//
+// It implements the commonly used subset of CommonMark: paragraphs, ATX headings, block quotes,
+// ordered and unordered lists, fenced and indented code blocks, thematic breaks, emphasis,
+// strong emphasis, strikethrough, code spans, links, autolinks and hard line breaks.
+//
+// The output is safe to embed in a page as is. Raw HTML in the source is escaped rather than
+// passed through, so the only tags ever produced are those in AllowedTags, the only attributes
+// are href and rel on links and start on ordered lists, and link URLs are limited to the http,
+// https and mailto schemes. Images are rendered as links, so that posts can't make readers
+// load arbitrary URLs.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AllowedTags are the HTML elements Render can produce.
+var AllowedTags = []string{
+	"a", "blockquote", "br", "code", "del", "em", "h1", "h2", "h3", "h4", "h5", "h6", "hr", "li", "ol", "p",
+	"pre", "strong", "ul",
+}
+
+var (
+	headingRe  = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*))?$`)
+	fenceRe    = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})(.*)$")
+	hrRe       = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	quoteRe    = regexp.MustCompile(`^ {0,3}> ?`)
+	listItemRe = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])(?:( +)(.*))?$`)
+)
+
+// Render returns the HTML rendering of the Markdown in src.
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	src = strings.ReplaceAll(src, "\x00", "�")
+	src = strings.ReplaceAll(src, "\t", "    ")
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"), false)
+	return b.String()
+}
+
+// renderBlocks renders lines as a sequence of blocks. In a tight list item, paragraphs are
+// rendered without their <p> tags.
+func renderBlocks(b *strings.Builder, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+		case indentation(line) >= 4:
+			i = renderIndentedCode(b, lines, i)
+		case fenceRe.MatchString(line) && validFence(line):
+			i = renderFencedCode(b, lines, i)
+		case headingRe.MatchString(line):
+			renderHeading(b, line)
+			i++
+		case hrRe.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+		case quoteRe.MatchString(line):
+			i = renderQuote(b, lines, i)
+		case listItemRe.MatchString(line):
+			i = renderList(b, lines, i)
+		default:
+			i = renderParagraph(b, lines, i, tight)
+		}
+	}
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// validFence reports whether a line matching fenceRe opens a code block: backtick fences can't
+// have backticks in their info string.
+func validFence(line string) bool {
+	m := fenceRe.FindStringSubmatch(line)
+	return m[2][0] != '`' || !strings.Contains(m[3], "`")
+}
+
+// startsBlock reports whether line begins a block that interrupts a paragraph.
+func startsBlock(line string) bool {
+	if headingRe.MatchString(line) || hrRe.MatchString(line) || quoteRe.MatchString(line) ||
+		fenceRe.MatchString(line) && validFence(line) {
+		return true
+	}
+	// Only lists that start at 1, and have content, interrupt a paragraph, so that lines such
+	// as "2024. was a good year" stay part of it.
+	if m := listItemRe.FindStringSubmatch(line); m != nil && m[4] != "" {
+		if n, err := strconv.Atoi(strings.TrimRight(m[2], ".)")); err != nil || n == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func renderIndentedCode(b *strings.Builder, lines []string, i int) int {
+	var code []string
+	for ; i < len(lines) && (isBlank(lines[i]) || indentation(lines[i]) >= 4); i++ {
+		code = append(code, strings.TrimPrefix(lines[i], "    "))
+	}
+	for len(code) > 0 && isBlank(code[len(code)-1]) {
+		code = code[:len(code)-1]
+	}
+	writeCode(b, code)
+	return i
+}
+
+func renderFencedCode(b *strings.Builder, lines []string, i int) int {
+	m := fenceRe.FindStringSubmatch(lines[i])
+	indent, fence := len(m[1]), m[2]
+	var code []string
+	for i++; i < len(lines); i++ {
+		line := lines[i]
+		if t := strings.TrimLeft(line, " "); indentation(line) < 4 && strings.HasPrefix(t, fence) &&
+			strings.Trim(t, fence[:1]+" ") == "" {
+			i++
+			break
+		}
+		code = append(code, line[min(indent, indentation(line)):])
+	}
+	writeCode(b, code)
+	return i
+}
+
+func writeCode(b *strings.Builder, code []string) {
+	b.WriteString("<pre><code>")
+	for _, line := range code {
+		b.WriteString(html.EscapeString(line))
+		b.WriteByte('\n')
+	}
+	b.WriteString("</code></pre>\n")
+}
+
+func renderHeading(b *strings.Builder, line string) {
+	m := headingRe.FindStringSubmatch(line)
+	level := strconv.Itoa(len(m[1]))
+	text := strings.TrimRight(m[2], " ")
+	// A closing sequence of #s is dropped when it is separated from the text by a space.
+	if trimmed := strings.TrimRight(text, "#"); trimmed == "" || strings.HasSuffix(trimmed, " ") {
+		text = strings.TrimRight(trimmed, " ")
+	}
+	b.WriteString("<h" + level + ">")
+	renderInline(b, text)
+	b.WriteString("</h" + level + ">\n")
+}
+
+func renderQuote(b *strings.Builder, lines []string, i int) int {
+	var inner []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if loc := quoteRe.FindStringIndex(line); loc != nil {
+			inner = append(inner, line[loc[1]:])
+			continue
+		}
+		// A paragraph inside the quote can continue on lines without the marker.
+		if !isBlank(line) && len(inner) > 0 && !isBlank(inner[len(inner)-1]) && !startsBlock(line) {
+			inner = append(inner, line)
+			continue
+		}
+		break
+	}
+	b.WriteString("<blockquote>\n")
+	renderBlocks(b, inner, false)
+	b.WriteString("</blockquote>\n")
+	return i
+}
+
+// listItem is the marker and content of one list item.
+type listItem struct {
+	marker string
+	lines  []string
+}
+
+// sameList reports whether marker b continues a list started with marker a: bullets must use
+// the same character and numbers the same delimiter.
+func sameList(a, b string) bool {
+	return a[len(a)-1] == b[len(b)-1] && isDigit(a[0]) == isDigit(b[0])
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func renderList(b *strings.Builder, lines []string, i int) int {
+	var items []listItem
+	var contentIndent int
+	loose, blankBefore := false, false
+	for i < len(lines) {
+		line := lines[i]
+		if m := listItemRe.FindStringSubmatch(line); m != nil && !hrRe.MatchString(line) &&
+			(len(items) == 0 || sameList(items[0].marker, m[2]) && indentation(line) < contentIndent) {
+			if blankBefore {
+				loose = true
+			}
+			spaces := len(m[3])
+			if spaces == 0 || spaces > 4 || m[4] == "" {
+				spaces = 1
+			}
+			contentIndent = len(m[1]) + len(m[2]) + spaces
+			content := m[4]
+			if len(m[3]) > 4 {
+				content = strings.Repeat(" ", len(m[3])-1) + content
+			}
+			items = append(items, listItem{marker: m[2], lines: []string{content}})
+			blankBefore = false
+			i++
+			continue
+		}
+		item := &items[len(items)-1]
+		if isBlank(line) {
+			// A blank line belongs to the list only if the list goes on after it.
+			j := i + 1
+			for j < len(lines) && isBlank(lines[j]) {
+				j++
+			}
+			if j == len(lines) || indentation(lines[j]) < contentIndent && !listItemRe.MatchString(lines[j]) {
+				break
+			}
+			item.lines = append(item.lines, "")
+			blankBefore = true
+			i++
+			continue
+		}
+		if indentation(line) >= contentIndent {
+			if blankBefore {
+				// Blocks separated by a blank line inside an item make the list loose.
+				loose = true
+				blankBefore = false
+			}
+			item.lines = append(item.lines, line[contentIndent:])
+			i++
+			continue
+		}
+		if !blankBefore && !startsBlock(line) && !listItemRe.MatchString(line) {
+			item.lines = append(item.lines, strings.TrimLeft(line, " "))
+			i++
+			continue
+		}
+		break
+	}
+
+	tag := "ul"
+	if first := items[0].marker; isDigit(first[0]) {
+		tag = "ol"
+		if n, _ := strconv.Atoi(first[:len(first)-1]); n != 1 {
+			b.WriteString(`<ol start="` + strconv.Itoa(n) + `">` + "\n")
+		} else {
+			b.WriteString("<ol>\n")
+		}
+	} else {
+		b.WriteString("<ul>\n")
+	}
+	for _, item := range items {
+		b.WriteString("<li>")
+		var inner strings.Builder
+		renderBlocks(&inner, item.lines, !loose)
+		b.WriteString(strings.TrimSuffix(inner.String(), "\n"))
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+func renderParagraph(b *strings.Builder, lines []string, i int, tight bool) int {
+	var text []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlank(line) || len(text) > 0 && startsBlock(line) {
+			break
+		}
+		text = append(text, strings.TrimLeft(line, " "))
+	}
+	// Lines ending in two spaces or a backslash end with a hard break, marked by \x00 for
+	// renderInline; Render has already replaced any \x00 in the source.
+	for j := 0; j < len(text)-1; j++ {
+		if strings.HasSuffix(text[j], "  ") {
+			text[j] = strings.TrimRight(text[j], " ") + "\x00"
+		} else if strings.HasSuffix(text[j], `\`) {
+			text[j] = strings.TrimSuffix(text[j], `\`) + "\x00"
+		}
+	}
+	last := len(text) - 1
+	text[last] = strings.TrimRight(text[last], " ")
+	if !tight {
+		b.WriteString("<p>")
+	}
+	renderInline(b, strings.Join(text, "\n"))
+	if !tight {
+		b.WriteString("</p>")
+	}
+	b.WriteByte('\n')
+	return i
+}
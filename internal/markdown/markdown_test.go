@@ -0,0 +1,86 @@
+package markdown
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			name: "raw HTML",
+			src:  "<div>\n<script>alert(1)</script>\n</div>",
+			want: "<p>&lt;div&gt;\n&lt;script&gt;alert(1)&lt;/script&gt;\n&lt;/div&gt;</p>\n",
+		},
+		{
+			name: "unsafe link destinations",
+			src:  "[x](javascript:alert(1)) [y](JaVaScRiPt:alert(1)) [z](  javascript:alert(1)) [d](data:text/html,x)",
+			want: "<p>x y z d</p>\n",
+		},
+		{
+			name: "autolinks",
+			src:  "<javascript:alert(1)> <mailto:a@b.com> <https://x.com>",
+			want: `<p>&lt;javascript:alert(1)&gt; ` +
+				`<a href="mailto:a@b.com" rel="nofollow noopener noreferrer">mailto:a@b.com</a> ` +
+				`<a href="https://x.com" rel="nofollow noopener noreferrer">https://x.com</a></p>` + "\n",
+		},
+		{
+			name: "headings",
+			src:  "# One\n## Two ##\n###### Six\n####### seven\n#nospace",
+			want: "<h1>One</h1>\n<h2>Two</h2>\n<h6>Six</h6>\n<p>####### seven\n#nospace</p>\n",
+		},
+		{
+			name: "tight lists",
+			src:  "- a\n- b\n\n1. one\n2. two\n\n3) three",
+			want: "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n" +
+				`<ol start="3">` + "\n<li>three</li>\n</ol>\n",
+		},
+		{
+			name: "loose list",
+			src:  "- a\n\n- b",
+			want: "<ul>\n<li><p>a</p></li>\n<li><p>b</p></li>\n</ul>\n",
+		},
+		{
+			name: "nested list",
+			src:  "- a\n  - nested",
+			want: "<ul>\n<li>a\n<ul>\n<li>nested</li>\n</ul></li>\n</ul>\n",
+		},
+		{
+			name: "code blocks",
+			src:  "```go\nif a < b {}\n```\n\n    indented <b>",
+			want: "<pre><code>if a &lt; b {}\n</code></pre>\n<pre><code>indented &lt;b&gt;\n</code></pre>\n",
+		},
+		{
+			name: "block quotes",
+			src:  "> quote\ncontinued\n> > nested\n\n> # heading\n> - item",
+			want: "<blockquote>\n<p>quote\ncontinued</p>\n<blockquote>\n<p>nested</p>\n</blockquote>\n</blockquote>\n" +
+				"<blockquote>\n<h1>heading</h1>\n<ul>\n<li>item</li>\n</ul>\n</blockquote>\n",
+		},
+		{
+			name: "thematic break",
+			src:  "a\n***\nb",
+			want: "<p>a</p>\n<hr>\n<p>b</p>\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := Render(tt.src); got != tt.want {
+			t.Errorf("%s: Render(%q) = %q, want %q", tt.name, tt.src, got, tt.want)
+		}
+	}
+}
+
+var tagRe = regexp.MustCompile(`</?([a-z0-9]+)`)
+
+// TestRenderAllowedTags checks that hostile input renders only to the tags in AllowedTags.
+func TestRenderAllowedTags(t *testing.T) {
+	src := "<script>alert(1)</script>\n\n> <img src=x onerror=alert(1)>\n\n- [a](javascript:x) <iframe>\n\n" +
+		"```\n</code></pre><script>\n```\n\n# <style> ![i](https://x/a.png)"
+	for _, m := range tagRe.FindAllStringSubmatch(Render(src), -1) {
+		if !slices.Contains(AllowedTags, m[1]) {
+			t.Errorf("rendered disallowed tag %q", m[0])
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"time"
+)
+
+var ErrSelfBlock = errors.New("users cannot block or mute themselves")
+
+type BlockStore struct {
+	db *sqlx.DB
+}
+
+func NewBlockStore(db *sql.DB) *BlockStore {
+	return &BlockStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// BlockEntry is one user in a list of blocked or muted users.
+type BlockEntry struct {
+	UserId    int       `db:"user_id" json:"-"`
+	User      Author    `db:"user" json:"user"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+func (b BlockEntry) cursor() Cursor {
+	return Cursor{CreatedAt: b.CreatedAt, Id: b.UserId}
+}
+
+// blockedBetween returns the condition for either of the users a and b, SQL expressions for
+// user ids, having blocked the other.
+func blockedBetween(a, b string) string {
+	return `EXISTS (SELECT 1 FROM blocks vb
+		WHERE vb.blocker_id = ` + a + ` AND vb.blocked_id = ` + b + `
+			OR vb.blocker_id = ` + b + ` AND vb.blocked_id = ` + a + `)`
+}
+
+// mutedBy returns the condition for viewer having muted user, both SQL expressions for user ids.
+func mutedBy(viewer, user string) string {
+	return `EXISTS (SELECT 1 FROM mutes vx WHERE vx.muter_id = ` + viewer + ` AND vx.muted_id = ` + user + `)`
+}
+
+// hiddenFrom returns the condition for what user does being kept from viewer, because either
+// blocked the other or viewer muted user.
+func hiddenFrom(user, viewer string) string {
+	return `(` + blockedBetween(user, viewer) + ` OR ` + mutedBy(viewer, user) + `)`
+}
+
+// Block makes blockerId block blockedId, ending the follows and follow requests between them in
+// either direction and taking their posts, and the reposts of them by others, out of each
+// other's timelines. It reports false without error when the block already existed.
+func (s *BlockStore) Block(ctx context.Context, blockerId, blockedId int) (bool, error) {
+	if blockerId == blockedId {
+		return false, ErrSelfBlock
+	}
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockUsers(ctx, tx, blockerId, blockedId); err != nil {
+		return false, err
+	}
+	result, err := tx.ExecContext(ctx, `INSERT INTO blocks (blocker_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		blockerId, blockedId)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert block: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to insert block: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM follows
+		WHERE follower_id = $1 AND followee_id = $2 OR follower_id = $2 AND followee_id = $1`,
+		blockerId, blockedId); err != nil {
+		return false, fmt.Errorf("failed to delete follows: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM follow_requests
+		WHERE follower_id = $1 AND followee_id = $2 OR follower_id = $2 AND followee_id = $1`,
+		blockerId, blockedId); err != nil {
+		return false, fmt.Errorf("failed to delete follow requests: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM home_timelines ht USING posts p
+		WHERE p.id = ht.post_id AND (ht.user_id = $1 AND (ht.author_id = $2 OR p.user_id = $2)
+			OR ht.user_id = $2 AND (ht.author_id = $1 OR p.user_id = $1))`,
+		blockerId, blockedId); err != nil {
+		return false, fmt.Errorf("failed to remove timeline entries: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit block: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Unblock removes the block, reporting false without error when there was none. The follows
+// the block ended are not restored.
+func (s *BlockStore) Unblock(ctx context.Context, blockerId, blockedId int) (bool, error) {
+	dml := `DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2`
+	result, err := s.db.ExecContext(ctx, dml, blockerId, blockedId)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete block: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete block: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Mute makes muterId mute mutedId, taking the posts and reposts of mutedId, and the reposts of
+// their posts by others, out of the timeline of muterId. It reports false without error when
+// the mute already existed.
+func (s *BlockStore) Mute(ctx context.Context, muterId, mutedId int) (bool, error) {
+	if muterId == mutedId {
+		return false, ErrSelfBlock
+	}
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `INSERT INTO mutes (muter_id, muted_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		muterId, mutedId)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert mute: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to insert mute: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM home_timelines ht USING posts p
+		WHERE p.id = ht.post_id AND ht.user_id = $1 AND (ht.author_id = $2 OR p.user_id = $2)`,
+		muterId, mutedId); err != nil {
+		return false, fmt.Errorf("failed to remove timeline entries: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit mute: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Unmute removes the mute, reporting false without error when there was none.
+func (s *BlockStore) Unmute(ctx context.Context, muterId, mutedId int) (bool, error) {
+	dml := `DELETE FROM mutes WHERE muter_id = $1 AND muted_id = $2`
+	result, err := s.db.ExecContext(ctx, dml, muterId, mutedId)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete mute: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete mute: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (s *BlockStore) IsBlocking(ctx context.Context, blockerId, blockedId int) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM blocks WHERE blocker_id = $1 AND blocked_id = $2)`
+	var blocking bool
+	if err := s.db.GetContext(ctx, &blocking, query, blockerId, blockedId); err != nil {
+		return false, fmt.Errorf("failed to query block: %w", err)
+	}
+	return blocking, nil
+}
+
+func (s *BlockStore) IsMuting(ctx context.Context, muterId, mutedId int) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM mutes WHERE muter_id = $1 AND muted_id = $2)`
+	var muting bool
+	if err := s.db.GetContext(ctx, &muting, query, muterId, mutedId); err != nil {
+		return false, fmt.Errorf("failed to query mute: %w", err)
+	}
+	return muting, nil
+}
+
+// ListBlocked returns a page of the users userId blocked, most recent first.
+func (s *BlockStore) ListBlocked(ctx context.Context, userId int, page Page) ([]BlockEntry, *Cursor, error) {
+	keyset, args := page.where("b.created_at", "b.blocked_id", []any{userId})
+	order, args := page.orderLimit("b.created_at", "b.blocked_id", args)
+	query := `SELECT b.blocked_id AS user_id, b.created_at, u.public_id AS "user.id", u.username AS "user.username"
+		FROM blocks b JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1 AND ` + keyset + ` ` + order
+	var entries []BlockEntry
+	if err := s.db.SelectContext(ctx, &entries, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list blocks: %w", err)
+	}
+	entries, next := paginate(entries, page.Limit, BlockEntry.cursor)
+	return entries, next, nil
+}
+
+// ListMuted returns a page of the users userId muted, most recent first.
+func (s *BlockStore) ListMuted(ctx context.Context, userId int, page Page) ([]BlockEntry, *Cursor, error) {
+	keyset, args := page.where("m.created_at", "m.muted_id", []any{userId})
+	order, args := page.orderLimit("m.created_at", "m.muted_id", args)
+	query := `SELECT m.muted_id AS user_id, m.created_at, u.public_id AS "user.id", u.username AS "user.username"
+		FROM mutes m JOIN users u ON u.id = m.muted_id
+		WHERE m.muter_id = $1 AND ` + keyset + ` ` + order
+	var entries []BlockEntry
+	if err := s.db.SelectContext(ctx, &entries, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list mutes: %w", err)
+	}
+	entries, next := paginate(entries, page.Limit, BlockEntry.cursor)
+	return entries, next, nil
+}
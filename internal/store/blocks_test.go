@@ -0,0 +1,328 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// inTimeline reports whether the timeline of user has an entry for postId.
+func inTimeline(t *testing.T, user *User, postId int) bool {
+	t.Helper()
+	var exists bool
+	if err := testDb.QueryRow(`SELECT EXISTS (SELECT 1 FROM home_timelines WHERE user_id = $1 AND post_id = $2)`,
+		user.Id, postId).Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+	return exists
+}
+
+// repostedPost has author write a post which reposter reposts. The repost is fanned out first,
+// so the timelines of users following both get the repost, whose author is reposter.
+func repostedPost(t *testing.T, s *Store, author, reposter *User) *Posts {
+	t.Helper()
+	ctx := context.Background()
+	post := createPost(t, s, author, NewPost{})
+	repostId, err := s.Reposts.Repost(ctx, reposter.Id, post.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Timelines.FanOutRepost(ctx, repostId, 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Timelines.FanOut(ctx, post.Id, 10); err != nil {
+		t.Fatal(err)
+	}
+	return post
+}
+
+func TestBlockRemovesPostsFromTimelines(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	blocker := createUser(t, s, "blocker")
+	blocked := createUser(t, s, "blocked")
+	reposter := createUser(t, s, "reposter")
+	follow(t, s, blocker, blocked)
+	follow(t, s, blocker, reposter)
+	follow(t, s, blocked, blocker)
+	follow(t, s, blocked, reposter)
+	follow(t, s, reposter, blocked)
+
+	blockedPost := repostedPost(t, s, blocked, reposter)
+	blockerPost := repostedPost(t, s, blocker, reposter)
+	if !inTimeline(t, blocker, blockedPost.Id) {
+		t.Fatal("repost not in the timeline before the block")
+	}
+
+	if _, err := s.Blocks.Block(ctx, blocker.Id, blocked.Id); err != nil {
+		t.Fatal(err)
+	}
+	if inTimeline(t, blocker, blockedPost.Id) {
+		t.Fatal("repost of the blocked user's post left in the blocker's timeline")
+	}
+	if inTimeline(t, blocked, blockerPost.Id) {
+		t.Fatal("repost of the blocker's post left in the blocked user's timeline")
+	}
+	if !inTimeline(t, reposter, blockedPost.Id) {
+		t.Fatal("post removed from the timeline of a follower of the blocked user")
+	}
+}
+
+func TestMuteRemovesPostsFromTimeline(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	muter := createUser(t, s, "muter")
+	muted := createUser(t, s, "muted")
+	reposter := createUser(t, s, "reposter")
+	other := createUser(t, s, "other")
+	follow(t, s, muter, muted)
+	follow(t, s, muter, reposter)
+	follow(t, s, reposter, muted)
+
+	post := repostedPost(t, s, muted, reposter)
+	// The muted user reposting the post of someone else.
+	reposted := repostedPost(t, s, other, muted)
+
+	if _, err := s.Blocks.Mute(ctx, muter.Id, muted.Id); err != nil {
+		t.Fatal(err)
+	}
+	if inTimeline(t, muter, post.Id) {
+		t.Fatal("repost of the muted user's post left in the muter's timeline")
+	}
+	if inTimeline(t, muter, reposted.Id) {
+		t.Fatal("repost by the muted user left in the muter's timeline")
+	}
+	// Only the muter's timeline changes.
+	if !inTimeline(t, reposter, post.Id) {
+		t.Fatal("post removed from the timeline of a follower of the muted user")
+	}
+
+	if _, err := s.Blocks.Unmute(ctx, muter.Id, muted.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Timelines.Backfill(ctx, muter.Id, muted.Id, 10); err != nil {
+		t.Fatal(err)
+	}
+	if !containsId(homeTimeline(t, s, muter, 10), post.Id) {
+		t.Fatal("post of the unmuted user not back in the timeline")
+	}
+}
+
+// TestBlocksAndMutesHideContent reads the posts, comments, reactions, reposts and notifications
+// of a user the viewer blocked, of one who blocked the viewer and of one the viewer muted, all
+// made before the block or mute, through feeds, search, comments, reactor and reposter lists and
+// notifications.
+func TestBlocksAndMutesHideContent(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	viewer := createUser(t, s, "viewer")
+	other := createUser(t, s, "other")
+	blocked := createUser(t, s, "blocked")
+	blocker := createUser(t, s, "blocker")
+	muted := createUser(t, s, "muted")
+	shown := createUser(t, s, "shown")
+	hidden := []*User{blocked, blocker, muted}
+
+	target := createPost(t, s, other, NewPost{})
+	posts := map[*User]*Posts{}
+	for _, user := range append([]*User{shown}, hidden...) {
+		follow(t, s, viewer, user)
+		posts[user] = createPost(t, s, user, NewPost{Content: "wombat"})
+		if _, err := s.Timelines.FanOut(ctx, posts[user].Id, 10); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Comments.CreateComment(ctx, target.Id, user.Id, nil, "hi"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Reactions.AddReaction(ctx, PostReactions, target.Id, user.Id, "like"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Reposts.Repost(ctx, user.Id, target.Id); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Notifications.Create(ctx, NewNotification{
+			UserId: viewer.Id, ActorId: user.Id, Kind: NotificationQuote, PostId: &target.Id,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Blocks.Block(ctx, viewer.Id, blocked.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Blocks.Block(ctx, blocker.Id, viewer.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Blocks.Mute(ctx, viewer.Id, muted.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	page := Page{Limit: 100}
+	surfaces := []struct {
+		name string
+		// list returns the users whose content viewer sees.
+		list func() []int
+	}{
+		{name: "user feed", list: func() []int {
+			var ids []int
+			for user, post := range posts {
+				listed, _, err := s.Posts.ListPostsByUser(ctx, user.Id, viewer.Id, page)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if containsId(postIds(listed), post.Id) {
+					ids = append(ids, user.Id)
+				}
+			}
+			return ids
+		}},
+		{name: "home timeline", list: func() []int {
+			return postAuthors(posts, homeTimeline(t, s, viewer, 10))
+		}},
+		{name: "home timeline pulled", list: func() []int {
+			if _, err := testDb.Exec(`DELETE FROM home_timelines WHERE user_id = $1`, viewer.Id); err != nil {
+				t.Fatal(err)
+			}
+			return postAuthors(posts, homeTimeline(t, s, viewer, -1))
+		}},
+		{name: "search", list: func() []int {
+			results, _, err := s.Search.SearchPosts(ctx, "wombat", "english", viewer.Id, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, r := range results {
+				ids = append(ids, r.Id)
+			}
+			return postAuthors(posts, ids)
+		}},
+		{name: "comments", list: func() []int {
+			comments, _, err := s.Comments.ListCommentsByPost(ctx, target.Id, viewer.Id, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, c := range comments {
+				ids = append(ids, c.UserId)
+			}
+			return ids
+		}},
+		{name: "reactors", list: func() []int {
+			reactors, _, err := s.Reactions.ListReactors(ctx, PostReactions, target.Id, "", viewer.Id, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, r := range reactors {
+				ids = append(ids, r.UserId)
+			}
+			return ids
+		}},
+		{name: "reposters", list: func() []int {
+			reposters, _, err := s.Reposts.ListReposters(ctx, target.Id, viewer.Id, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, r := range reposters {
+				ids = append(ids, r.UserId)
+			}
+			return ids
+		}},
+		{name: "notifications", list: func() []int {
+			notifications, _, err := s.Notifications.List(ctx, viewer.Id, false, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, n := range notifications {
+				ids = append(ids, n.ActorId)
+			}
+			return ids
+		}},
+	}
+
+	for _, surface := range surfaces {
+		ids := surface.list()
+		if !containsId(ids, shown.Id) {
+			t.Errorf("%s: content of a followed user missing", surface.name)
+		}
+		for _, user := range hidden {
+			if containsId(ids, user.Id) {
+				t.Errorf("%s: content of %s shown", surface.name, user.Username)
+			}
+		}
+	}
+}
+
+// postAuthors returns the ids of the users whose posts, of those in posts, are in ids.
+func postAuthors(posts map[*User]*Posts, ids []int) []int {
+	var authors []int
+	for user, post := range posts {
+		if containsId(ids, post.Id) {
+			authors = append(authors, user.Id)
+		}
+	}
+	return authors
+}
+
+func TestFollowBlocked(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	blocker := createUser(t, s, "blocker")
+	blocked := createUser(t, s, "blocked")
+	if _, err := s.Blocks.Block(ctx, blocker.Id, blocked.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pair := range [][2]*User{{blocker, blocked}, {blocked, blocker}} {
+		follower, followee := pair[0], pair[1]
+		if _, err := s.Follows.Follow(ctx, follower.Id, followee.Id); !errors.Is(err, ErrBlocked) {
+			t.Errorf("%s following %s: got %v, want %v", follower.Username, followee.Username, err, ErrBlocked)
+		}
+		if _, err := s.Follows.RequestFollow(ctx, follower.Id, followee.Id); !errors.Is(err, ErrBlocked) {
+			t.Errorf("%s asking to follow %s: got %v, want %v", follower.Username, followee.Username, err, ErrBlocked)
+		}
+		if following, err := s.Follows.IsFollowing(ctx, follower.Id, followee.Id); err != nil || following {
+			t.Errorf("%s follows %s despite the block: %v, %v", follower.Username, followee.Username, following, err)
+		}
+	}
+
+	if _, err := s.Blocks.Unblock(ctx, blocker.Id, blocked.Id); err != nil {
+		t.Fatal(err)
+	}
+	if created, err := s.Follows.Follow(ctx, blocker.Id, blocked.Id); err != nil || !created {
+		t.Fatalf("following after the unblock: %v, %v", created, err)
+	}
+}
+
+func TestBlockBackAndUnblock(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	first := createUser(t, s, "first")
+	second := createUser(t, s, "second")
+
+	if created, err := s.Blocks.Block(ctx, first.Id, second.Id); err != nil || !created {
+		t.Fatalf("blocking: %v, %v", created, err)
+	}
+	// Blocking, and muting, a user who blocked you.
+	if created, err := s.Blocks.Block(ctx, second.Id, first.Id); err != nil || !created {
+		t.Fatalf("blocking back: %v, %v", created, err)
+	}
+	if created, err := s.Blocks.Mute(ctx, second.Id, first.Id); err != nil || !created {
+		t.Fatalf("muting a user who blocked you: %v, %v", created, err)
+	}
+
+	// Either can lift their own block, leaving the other in place.
+	if removed, err := s.Blocks.Unblock(ctx, first.Id, second.Id); err != nil || !removed {
+		t.Fatalf("unblocking after a mutual block: %v, %v", removed, err)
+	}
+	if blocking, err := s.Blocks.IsBlocking(ctx, second.Id, first.Id); err != nil || !blocking {
+		t.Fatalf("block back lifted along with the first block: %v, %v", blocking, err)
+	}
+	if removed, err := s.Blocks.Unblock(ctx, second.Id, first.Id); err != nil || !removed {
+		t.Fatalf("unblocking back: %v, %v", removed, err)
+	}
+	if created, err := s.Follows.Follow(ctx, first.Id, second.Id); err != nil || !created {
+		t.Fatalf("following after both blocks were lifted: %v, %v", created, err)
+	}
+}
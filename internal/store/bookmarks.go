@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+var ErrCollectionExists = errors.New("a collection with that name already exists")
+
+type BookmarkStore struct {
+	db *sqlx.DB
+}
+
+func NewBookmarkStore(db *sql.DB) *BookmarkStore {
+	return &BookmarkStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// BookmarkCollection is a named group of bookmarks, only ever shown to the user who owns it.
+type BookmarkCollection struct {
+	Id            int       `db:"id" json:"-"`
+	PublicId      string    `db:"public_id" json:"id"`
+	UserId        int       `db:"user_id" json:"-"`
+	Name          string    `db:"name" json:"name"`
+	BookmarkCount int       `db:"bookmark_count" json:"bookmark_count"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+func (c BookmarkCollection) cursor() Cursor {
+	return Cursor{CreatedAt: c.CreatedAt, Id: c.Id}
+}
+
+const collectionSelect = `SELECT bc.id, bc.public_id, bc.user_id, bc.name, bc.created_at,
+		(SELECT COUNT(*) FROM bookmarks b WHERE b.collection_id = bc.id) AS bookmark_count
+	FROM bookmark_collections bc`
+
+// CreateCollection adds a collection for userId. It returns ErrCollectionExists if userId
+// already has one named name.
+func (s *BookmarkStore) CreateCollection(ctx context.Context, userId int, name string) (*BookmarkCollection, error) {
+	dml := `INSERT INTO bookmark_collections (user_id, name) VALUES ($1, $2) ON CONFLICT DO NOTHING RETURNING id`
+	var id int
+	err := s.db.GetContext(ctx, &id, dml, userId, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCollectionExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert bookmark collection: %w", err)
+	}
+	return s.getCollectionById(ctx, id)
+}
+
+func (s *BookmarkStore) getCollectionById(ctx context.Context, id int) (*BookmarkCollection, error) {
+	query := collectionSelect + ` WHERE bc.id = $1`
+	var collection BookmarkCollection
+	if err := s.db.GetContext(ctx, &collection, query, id); err != nil {
+		return nil, fmt.Errorf("failed to query bookmark collection by id: %w", err)
+	}
+	return &collection, nil
+}
+
+// GetCollection returns the collection publicId if it belongs to userId.
+func (s *BookmarkStore) GetCollection(ctx context.Context, publicId string, userId int) (*BookmarkCollection, error) {
+	query := collectionSelect + ` WHERE bc.public_id = $1 AND bc.user_id = $2`
+	var collection BookmarkCollection
+	if err := s.db.GetContext(ctx, &collection, query, publicId, userId); err != nil {
+		return nil, fmt.Errorf("failed to query bookmark collection: %w", err)
+	}
+	return &collection, nil
+}
+
+// ListCollections returns a page of the collections of userId, newest first.
+func (s *BookmarkStore) ListCollections(ctx context.Context, userId int, page Page) ([]BookmarkCollection, *Cursor, error) {
+	keyset, args := page.where("bc.created_at", "bc.id", []any{userId})
+	order, args := page.orderLimit("bc.created_at", "bc.id", args)
+	query := collectionSelect + ` WHERE bc.user_id = $1 AND ` + keyset + ` ` + order
+	var collections []BookmarkCollection
+	if err := s.db.SelectContext(ctx, &collections, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list bookmark collections: %w", err)
+	}
+	collections, next := paginate(collections, page.Limit, BookmarkCollection.cursor)
+	return collections, next, nil
+}
+
+// RenameCollection renames collection id. It returns ErrCollectionExists if its owner already
+// has another collection named name.
+func (s *BookmarkStore) RenameCollection(ctx context.Context, id int, name string) (*BookmarkCollection, error) {
+	dml := `UPDATE bookmark_collections bc SET name = $2
+		WHERE bc.id = $1 AND NOT EXISTS (
+			SELECT 1 FROM bookmark_collections o WHERE o.user_id = bc.user_id AND o.name = $2 AND o.id <> bc.id
+		)`
+	result, err := s.db.ExecContext(ctx, dml, id, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename bookmark collection: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename bookmark collection: %w", err)
+	}
+	if n == 0 {
+		return nil, ErrCollectionExists
+	}
+	return s.getCollectionById(ctx, id)
+}
+
+// DeleteCollection removes collection id, leaving the bookmarks in it unsorted.
+func (s *BookmarkStore) DeleteCollection(ctx context.Context, id int) error {
+	dml := `DELETE FROM bookmark_collections WHERE id = $1`
+	if _, err := s.db.ExecContext(ctx, dml, id); err != nil {
+		return fmt.Errorf("failed to delete bookmark collection: %w", err)
+	}
+	return nil
+}
+
+// Bookmark saves postId for userId in collectionId, or unsorted when it is nil. Bookmarking a
+// post again moves it to collectionId and keeps its place in the list of bookmarks.
+func (s *BookmarkStore) Bookmark(ctx context.Context, userId, postId int, collectionId *int) error {
+	dml := `INSERT INTO bookmarks (user_id, post_id, collection_id) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, post_id) DO UPDATE SET collection_id = EXCLUDED.collection_id`
+	if _, err := s.db.ExecContext(ctx, dml, userId, postId, collectionId); err != nil {
+		return fmt.Errorf("failed to insert bookmark: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the bookmark of postId by userId, doing nothing if there is none.
+func (s *BookmarkStore) Remove(ctx context.Context, userId, postId int) error {
+	dml := `DELETE FROM bookmarks WHERE user_id = $1 AND post_id = $2`
+	if _, err := s.db.ExecContext(ctx, dml, userId, postId); err != nil {
+		return fmt.Errorf("failed to delete bookmark: %w", err)
+	}
+	return nil
+}
+
+// List returns a page of the posts userId bookmarked and may still see, only those in
+// collectionId unless it is nil, most recently bookmarked first.
+func (s *BookmarkStore) List(ctx context.Context, userId int, collectionId *int, page Page) ([]Posts, *Cursor, error) {
+	keyset, args := page.where("b.created_at", "b.post_id", []any{userId, collectionId})
+	order, args := page.orderLimit("e.created_at", "e.post_id", args)
+	query := `WITH entries AS (
+			SELECT b.post_id, b.created_at, NULL::BIGINT AS repost_id FROM bookmarks b
+			WHERE b.user_id = $1 AND ($2::BIGINT IS NULL OR b.collection_id = $2) AND ` + keyset + `
+		)
+		` + entrySelect + ` WHERE ` + listedPost("p", "$1") + ` ` + order
+	posts, next, err := selectEntries(ctx, s.db, query, args, page.Limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list bookmarks: %w", err)
+	}
+	return posts, next, nil
+}
+
+// BookmarkedBy returns which of postIds userId bookmarked.
+func (s *BookmarkStore) BookmarkedBy(ctx context.Context, postIds []int, userId int) (map[int]bool, error) {
+	query := `SELECT post_id FROM bookmarks WHERE post_id = ANY($1) AND user_id = $2`
+	var ids []int
+	if err := s.db.SelectContext(ctx, &ids, query, pq.Array(postIds), userId); err != nil {
+		return nil, fmt.Errorf("failed to query bookmarks: %w", err)
+	}
+	bookmarked := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		bookmarked[id] = true
+	}
+	return bookmarked, nil
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestBookmarkCollections(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	user := createUser(t, s, "user")
+	other := createUser(t, s, "other")
+	author := createUser(t, s, "author")
+	first := createPost(t, s, author, NewPost{})
+	second := createPost(t, s, author, NewPost{})
+
+	reading, err := s.Bookmarks.CreateCollection(ctx, user.Id, "reading")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Bookmarks.CreateCollection(ctx, user.Id, "reading"); !errors.Is(err, ErrCollectionExists) {
+		t.Fatalf("second collection with the same name: got %v, want %v", err, ErrCollectionExists)
+	}
+	if _, err := s.Bookmarks.CreateCollection(ctx, other.Id, "reading"); err != nil {
+		t.Fatalf("another user's collection with the same name: %v", err)
+	}
+	// Collections are private to their owner.
+	if _, err := s.Bookmarks.GetCollection(ctx, reading.PublicId, other.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("another user loaded the collection: %v", err)
+	}
+
+	list := func(collectionId *int) []int {
+		t.Helper()
+		posts, _, err := s.Bookmarks.List(ctx, user.Id, collectionId, Page{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return postIds(posts)
+	}
+	if err := s.Bookmarks.Bookmark(ctx, user.Id, first.Id, &reading.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Bookmarks.Bookmark(ctx, user.Id, second.Id, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := list(&reading.Id), []int{first.Id}; !slices.Equal(got, want) {
+		t.Fatalf("collection = %v, want %v", got, want)
+	}
+	if got, want := list(nil), []int{second.Id, first.Id}; !slices.Equal(got, want) {
+		t.Fatalf("bookmarks = %v, want %v", got, want)
+	}
+
+	// Bookmarking again moves the bookmark and keeps its place.
+	if err := s.Bookmarks.Bookmark(ctx, user.Id, second.Id, &reading.Id); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := list(&reading.Id), []int{second.Id, first.Id}; !slices.Equal(got, want) {
+		t.Fatalf("collection after moving a bookmark in = %v, want %v", got, want)
+	}
+	collection, err := s.Bookmarks.GetCollection(ctx, reading.PublicId, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collection.BookmarkCount != 2 {
+		t.Fatalf("bookmark count = %d, want 2", collection.BookmarkCount)
+	}
+
+	later, err := s.Bookmarks.CreateCollection(ctx, user.Id, "later")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Bookmarks.RenameCollection(ctx, later.Id, "reading"); !errors.Is(err, ErrCollectionExists) {
+		t.Fatalf("renaming to a taken name: got %v, want %v", err, ErrCollectionExists)
+	}
+	if renamed, err := s.Bookmarks.RenameCollection(ctx, later.Id, "someday"); err != nil || renamed.Name != "someday" {
+		t.Fatalf("renaming: %+v, %v", renamed, err)
+	}
+
+	// Deleting a collection leaves its bookmarks unsorted.
+	if err := s.Bookmarks.DeleteCollection(ctx, reading.Id); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := list(nil), []int{second.Id, first.Id}; !slices.Equal(got, want) {
+		t.Fatalf("bookmarks after deleting their collection = %v, want %v", got, want)
+	}
+	if err := s.Bookmarks.Remove(ctx, user.Id, first.Id); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := list(nil), []int{second.Id}; !slices.Equal(got, want) {
+		t.Fatalf("bookmarks after removing one = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,251 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+type CommentStore struct {
+	db *sqlx.DB
+}
+
+func NewCommentStore(db *sql.DB) *CommentStore {
+	return &CommentStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+type Comment struct {
+	Id           int    `db:"id" json:"-"`
+	PublicId     string `db:"public_id" json:"id"`
+	UserId       int    `db:"user_id" json:"-"`
+	PostId       int    `db:"post_id" json:"-"`
+	PostPublicId string `db:"post_public_id" json:"post_id"`
+	ParentId     *int   `db:"parent_id" json:"-"`
+	// ParentPublicId is nil for top-level comments.
+	ParentPublicId *string        `db:"parent_public_id" json:"parent_id,omitempty"`
+	Author         Author         `db:"author" json:"author"`
+	Content        string         `db:"content" json:"content"`
+	ReplyCount     int            `db:"reply_count" json:"reply_count"`
+	CreatedAt      time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time      `db:"updated_at" json:"updated_at"`
+	DeletedAt      *time.Time     `db:"deleted_at" json:"-"`
+	DeletedBy      *int           `db:"deleted_by" json:"-"`
+	Reactions      map[string]int `db:"-" json:"reactions"`
+	// MyReactions holds the viewer's own reactions when there is a signed-in viewer.
+	MyReactions []string        `db:"-" json:"my_reactions,omitempty"`
+	Mentions    []MentionEntity `db:"-" json:"mentions"`
+	Replies     []*Comment      `db:"-" json:"replies,omitempty"`
+	// RepliesNext continues Replies when only part of them were loaded. It is sent to clients
+	// encoded as RepliesNextCursor.
+	RepliesNext       *Cursor `db:"-" json:"-"`
+	RepliesNextCursor string  `db:"-" json:"replies_next_cursor,omitempty"`
+}
+
+func (c Comment) cursor() Cursor {
+	return Cursor{CreatedAt: c.CreatedAt, Id: c.Id}
+}
+
+const commentSelect = `SELECT c.*, p.public_id AS post_public_id, pc.public_id AS parent_public_id,
+		u.public_id AS "author.id", u.username AS "author.username",
+		(SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id AND r.deleted_at IS NULL) AS reply_count
+	FROM comments c
+	JOIN posts p ON p.id = c.post_id
+	JOIN users u ON u.id = c.user_id
+	LEFT JOIN comments pc ON pc.id = c.parent_id`
+
+// shownComment returns the condition for the comment aliased alias not being hidden from viewer,
+// a SQL expression for the id of a user, by a block or mute of its author. Replies beneath a
+// hidden comment are hidden with it.
+func shownComment(alias, viewer string) string {
+	return `NOT ` + hiddenFrom(alias+`.user_id`, viewer)
+}
+
+// CreateComment adds a comment to postId, as a reply to parentId when it is not nil.
+func (s *CommentStore) CreateComment(ctx context.Context, postId, userId int, parentId *int, content string) (*Comment, error) {
+	dml := `INSERT INTO comments (post_id, user_id, parent_id, content) VALUES ($1, $2, $3, $4) RETURNING id`
+	var commentId int
+	if err := s.db.GetContext(ctx, &commentId, dml, postId, userId, parentId, content); err != nil {
+		return nil, fmt.Errorf("failed to insert comment: %w", err)
+	}
+	return s.GetCommentById(ctx, commentId)
+}
+
+func (s *CommentStore) GetCommentById(ctx context.Context, id int) (*Comment, error) {
+	query := commentSelect + ` WHERE c.id = $1 AND c.deleted_at IS NULL`
+	var comment Comment
+	if err := s.db.GetContext(ctx, &comment, query, id); err != nil {
+		return nil, fmt.Errorf("failed to query comment by id: %w", err)
+	}
+	return &comment, nil
+}
+
+// GetCommentByPublicId returns the comment unless viewerId blocked or was blocked by its author.
+func (s *CommentStore) GetCommentByPublicId(ctx context.Context, publicId string, viewerId int) (*Comment, error) {
+	query := commentSelect + ` WHERE c.public_id = $1 AND c.deleted_at IS NULL AND NOT ` + blockedBetween("c.user_id", "$2")
+	var comment Comment
+	if err := s.db.GetContext(ctx, &comment, query, publicId, viewerId); err != nil {
+		return nil, fmt.Errorf("failed to query comment by public id: %w", err)
+	}
+	return &comment, nil
+}
+
+// ListCommentsByPost returns a page of the top-level comments on postId shown to viewerId,
+// newest first, and the cursor of the next page.
+func (s *CommentStore) ListCommentsByPost(ctx context.Context, postId, viewerId int, page Page) ([]Comment, *Cursor, error) {
+	keyset, args := page.where("c.created_at", "c.id", []any{postId, viewerId})
+	order, args := page.orderLimit("c.created_at", "c.id", args)
+	query := commentSelect + ` WHERE c.post_id = $1 AND c.parent_id IS NULL AND c.deleted_at IS NULL
+		AND ` + shownComment("c", "$2") + ` AND ` + keyset + ` ` + order
+	var comments []Comment
+	if err := s.db.SelectContext(ctx, &comments, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+	comments, next := paginate(comments, page.Limit, Comment.cursor)
+	return comments, next, nil
+}
+
+// ListReplies returns a page of the direct replies to parentId shown to viewerId, newest first,
+// and the cursor of the next page.
+func (s *CommentStore) ListReplies(ctx context.Context, parentId, viewerId int, page Page) ([]Comment, *Cursor, error) {
+	keyset, args := page.where("c.created_at", "c.id", []any{parentId, viewerId})
+	order, args := page.orderLimit("c.created_at", "c.id", args)
+	query := commentSelect + ` WHERE c.parent_id = $1 AND c.deleted_at IS NULL
+		AND ` + shownComment("c", "$2") + ` AND ` + keyset + ` ` + order
+	var comments []Comment
+	if err := s.db.SelectContext(ctx, &comments, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list replies: %w", err)
+	}
+	comments, next := paginate(comments, page.Limit, Comment.cursor)
+	return comments, next, nil
+}
+
+// ExpandReplies attaches the first page of the replies shown to viewerId, up to limit per
+// comment, to each of comments and to their replies in turn, down to depth levels. Comments with
+// more replies than were loaded get a RepliesNext cursor for ListReplies. It issues one query per
+// level.
+func (s *CommentStore) ExpandReplies(ctx context.Context, comments []Comment, viewerId, depth, limit int) error {
+	level := make([]*Comment, len(comments))
+	for i := range comments {
+		level[i] = &comments[i]
+	}
+	for d := 0; d < depth && len(level) > 0; d++ {
+		parentIds := make([]int64, len(level))
+		for i, c := range level {
+			parentIds[i] = int64(c.Id)
+		}
+		query := `WITH ranked AS (
+			SELECT r.id, ROW_NUMBER() OVER (PARTITION BY r.parent_id ORDER BY r.created_at DESC, r.id DESC) AS n
+			FROM comments r WHERE r.parent_id = ANY($1) AND r.deleted_at IS NULL AND ` + shownComment("r", "$3") + `
+		)
+		` + commentSelect + `
+		WHERE c.id IN (SELECT id FROM ranked WHERE n <= $2)
+		ORDER BY c.created_at DESC, c.id DESC`
+		var replies []Comment
+		if err := s.db.SelectContext(ctx, &replies, query, pq.Array(parentIds), limit+1, viewerId); err != nil {
+			return fmt.Errorf("failed to expand replies: %w", err)
+		}
+		byParent := make(map[int][]Comment)
+		for _, reply := range replies {
+			byParent[*reply.ParentId] = append(byParent[*reply.ParentId], reply)
+		}
+		var nextLevel []*Comment
+		for _, parent := range level {
+			page, next := paginate(byParent[parent.Id], limit, Comment.cursor)
+			for i := range page {
+				parent.Replies = append(parent.Replies, &page[i])
+				nextLevel = append(nextLevel, &page[i])
+			}
+			parent.RepliesNext = next
+		}
+		level = nextLevel
+	}
+	return nil
+}
+
+// GetThread returns rootId with every reply beneath it shown to viewerId, down to depth levels,
+// assembled into a tree newest first.
+func (s *CommentStore) GetThread(ctx context.Context, rootId, viewerId, depth int) (*Comment, error) {
+	query := `WITH RECURSIVE thread AS (
+			SELECT id, 0 AS depth FROM comments WHERE id = $1 AND deleted_at IS NULL
+			UNION ALL
+			SELECT r.id, t.depth + 1 FROM comments r JOIN thread t ON r.parent_id = t.id
+			WHERE t.depth < $2 AND r.deleted_at IS NULL AND ` + shownComment("r", "$3") + `
+		)
+		` + commentSelect + `
+		WHERE c.id IN (SELECT id FROM thread)
+		ORDER BY c.created_at DESC, c.id DESC`
+	var comments []Comment
+	if err := s.db.SelectContext(ctx, &comments, query, rootId, depth, viewerId); err != nil {
+		return nil, fmt.Errorf("failed to query thread: %w", err)
+	}
+	byId := make(map[int]*Comment, len(comments))
+	for i := range comments {
+		byId[comments[i].Id] = &comments[i]
+	}
+	root, ok := byId[rootId]
+	if !ok {
+		return nil, fmt.Errorf("failed to query thread: %w", sql.ErrNoRows)
+	}
+	for i := range comments {
+		if c := &comments[i]; c.Id != rootId && c.ParentId != nil {
+			if parent, ok := byId[*c.ParentId]; ok {
+				parent.Replies = append(parent.Replies, c)
+			}
+		}
+	}
+	return root, nil
+}
+
+func (s *CommentStore) UpdateComment(ctx context.Context, id int, content string) (*Comment, error) {
+	dml := `UPDATE comments SET content = $2 WHERE id = $1`
+	if _, err := s.db.ExecContext(ctx, dml, id, content); err != nil {
+		return nil, fmt.Errorf("failed to update comment: %w", err)
+	}
+	return s.GetCommentById(ctx, id)
+}
+
+// DeleteComment hides comment id and every reply beneath it until they are purged, recording
+// that deletedBy deleted them.
+func (s *CommentStore) DeleteComment(ctx context.Context, id, deletedBy int) error {
+	dml := `WITH RECURSIVE subtree AS (
+			SELECT id FROM comments WHERE id = $1
+			UNION ALL
+			SELECT r.id FROM comments r JOIN subtree s ON r.parent_id = s.id
+		)
+		UPDATE comments SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $2
+		WHERE id IN (SELECT id FROM subtree) AND deleted_at IS NULL`
+	if _, err := s.db.ExecContext(ctx, dml, id, deletedBy); err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+	return nil
+}
+
+// RestoreComment undoes the deletion of the comment publicId on postId by userId, if userId wrote
+// it, deleted it less than retention ago and its parent is still there. The replies deleted along
+// with it are restored too; those deleted before it stay deleted. It returns sql.ErrNoRows if
+// there is no such comment.
+func (s *CommentStore) RestoreComment(ctx context.Context, postId int, publicId string, userId int, retention time.Duration) (*Comment, error) {
+	dml := `WITH RECURSIVE root AS (
+			SELECT c.id, c.deleted_at FROM comments c
+			WHERE c.public_id = $1 AND c.post_id = $2 AND c.user_id = $3 AND c.deleted_by = $3
+				AND c.deleted_at > CURRENT_TIMESTAMP - make_interval(secs => $4)
+				AND NOT EXISTS (SELECT 1 FROM comments pc WHERE pc.id = c.parent_id AND pc.deleted_at IS NOT NULL)
+		), subtree AS (
+			SELECT id, deleted_at FROM root
+			UNION ALL
+			SELECT r.id, s.deleted_at FROM comments r JOIN subtree s ON r.parent_id = s.id AND r.deleted_at = s.deleted_at
+		), restored AS (
+			UPDATE comments SET deleted_at = NULL, deleted_by = NULL WHERE id IN (SELECT id FROM subtree)
+		)
+		SELECT id FROM root`
+	var id int
+	if err := s.db.GetContext(ctx, &id, dml, publicId, postId, userId, retention.Seconds()); err != nil {
+		return nil, fmt.Errorf("failed to restore comment: %w", err)
+	}
+	return s.GetCommentById(ctx, id)
+}
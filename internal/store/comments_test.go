@@ -0,0 +1,269 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func createComment(t *testing.T, s *Store, post *Posts, author *User, parent *Comment) *Comment {
+	t.Helper()
+	var parentId *int
+	if parent != nil {
+		parentId = &parent.Id
+	}
+	comment, err := s.Comments.CreateComment(context.Background(), post.Id, author.Id, parentId, "comment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return comment
+}
+
+func TestRestoreCommentRestoresRepliesDeletedWithIt(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	replier := createUser(t, s, "replier")
+	post := createPost(t, s, author, NewPost{})
+	comment := createComment(t, s, post, author, nil)
+	kept := createComment(t, s, post, replier, comment)
+	removed := createComment(t, s, post, replier, comment)
+
+	if err := s.Comments.DeleteComment(ctx, removed.Id, replier.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDb.Exec(`UPDATE comments SET deleted_at = deleted_at - INTERVAL '1 minute' WHERE id = $1`,
+		removed.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Comments.DeleteComment(ctx, comment.Id, author.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Comments.RestoreComment(ctx, post.Id, comment.PublicId, replier.Id, time.Hour); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("restore by another user error = %v, want sql.ErrNoRows", err)
+	}
+	restored, err := s.Comments.RestoreComment(ctx, post.Id, comment.PublicId, author.Id, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Id != comment.Id {
+		t.Fatalf("restored comment %d, want %d", restored.Id, comment.Id)
+	}
+	if _, err := s.Comments.GetCommentById(ctx, kept.Id); err != nil {
+		t.Fatalf("reply deleted with the comment wasn't restored: %v", err)
+	}
+	if _, err := s.Comments.GetCommentById(ctx, removed.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("reply deleted before the comment was restored: %v", err)
+	}
+}
+
+func TestRestoreCommentDeletedByModerator(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	moderator := createUser(t, s, "moderator")
+	post := createPost(t, s, author, NewPost{})
+	comment := createComment(t, s, post, author, nil)
+
+	if err := s.Comments.DeleteComment(ctx, comment.Id, moderator.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Comments.RestoreComment(ctx, post.Id, comment.PublicId, author.Id, time.Hour); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("author restored a comment a moderator deleted: %v", err)
+	}
+}
+
+func TestPurgeDeletedConcurrently(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	for i := 0; i < 5; i++ {
+		post := createPost(t, s, author, NewPost{})
+		if err := s.Posts.DeletePost(ctx, post.Id, author.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Posts.PurgeDeleted(ctx, 0, 500)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent purge failed: %v", err)
+		}
+	}
+}
+
+func TestListCommentsByPost(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	post := createPost(t, s, author, NewPost{})
+	other := createPost(t, s, author, NewPost{})
+	first := createComment(t, s, post, author, nil)
+	second := createComment(t, s, post, author, nil)
+	createComment(t, s, post, author, first)
+	createComment(t, s, other, author, nil)
+
+	// Top-level comments on the post only, newest first, a page at a time.
+	var got []int
+	page := Page{Limit: 1}
+	for {
+		comments, next, err := s.Comments.ListCommentsByPost(ctx, post.Id, author.Id, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range comments {
+			got = append(got, c.Id)
+		}
+		if next == nil {
+			break
+		}
+		page.Cursor = next
+	}
+	if want := []int{second.Id, first.Id}; !slices.Equal(got, want) {
+		t.Fatalf("comments = %v, want %v", got, want)
+	}
+}
+
+func TestUpdateAndDeleteComment(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	post := createPost(t, s, author, NewPost{})
+	comment := createComment(t, s, post, author, nil)
+
+	updated, err := s.Comments.UpdateComment(ctx, comment.Id, "edited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Content != "edited" || updated.PublicId != comment.PublicId {
+		t.Fatalf("updated comment = %+v", updated)
+	}
+
+	if err := s.Comments.DeleteComment(ctx, comment.Id, author.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Comments.GetCommentByPublicId(ctx, comment.PublicId, author.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("deleted comment loaded: %v", err)
+	}
+	comments, _, err := s.Comments.ListCommentsByPost(ctx, post.Id, author.Id, Page{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 0 {
+		t.Fatalf("deleted comment listed: %+v", comments)
+	}
+}
+
+// commentIds returns the ids of comments.
+func commentIds(comments []*Comment) []int {
+	ids := make([]int, len(comments))
+	for i, c := range comments {
+		ids[i] = c.Id
+	}
+	return ids
+}
+
+func TestExpandReplies(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	post := createPost(t, s, author, NewPost{})
+	root := createComment(t, s, post, author, nil)
+	oldest := createComment(t, s, post, author, root)
+	middle := createComment(t, s, post, author, root)
+	newest := createComment(t, s, post, author, root)
+	nested := createComment(t, s, post, author, newest)
+	createComment(t, s, post, author, nested)
+
+	comments := []Comment{*root}
+	if err := s.Comments.ExpandReplies(ctx, comments, author.Id, 2, 2); err != nil {
+		t.Fatal(err)
+	}
+	expanded := comments[0]
+	if got, want := commentIds(expanded.Replies), []int{newest.Id, middle.Id}; !slices.Equal(got, want) {
+		t.Fatalf("replies = %v, want %v", got, want)
+	}
+	if got, want := commentIds(expanded.Replies[0].Replies), []int{nested.Id}; !slices.Equal(got, want) {
+		t.Fatalf("nested replies = %v, want %v", got, want)
+	}
+	// Only two levels are loaded.
+	if replies := expanded.Replies[0].Replies[0].Replies; len(replies) != 0 {
+		t.Fatalf("third level loaded: %v", commentIds(replies))
+	}
+	if expanded.ReplyCount != 3 {
+		t.Fatalf("reply count = %d, want 3", expanded.ReplyCount)
+	}
+
+	// The rest of the replies continue from the cursor.
+	if expanded.RepliesNext == nil {
+		t.Fatal("no cursor for the replies left out")
+	}
+	if expanded.Replies[1].RepliesNext != nil {
+		t.Fatal("cursor for a reply without replies")
+	}
+	rest, next, err := s.Comments.ListReplies(ctx, root.Id, author.Id, Page{Limit: 2, Cursor: expanded.RepliesNext})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 1 || rest[0].Id != oldest.Id || next != nil {
+		t.Fatalf("rest of the replies = %+v, next %v", rest, next)
+	}
+}
+
+func TestGetThread(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	post := createPost(t, s, author, NewPost{})
+	root := createComment(t, s, post, author, nil)
+	older := createComment(t, s, post, author, root)
+	newer := createComment(t, s, post, author, root)
+	nested := createComment(t, s, post, author, older)
+	deeper := createComment(t, s, post, author, nested)
+	removed := createComment(t, s, post, author, newer)
+	if err := s.Comments.DeleteComment(ctx, removed.Id, author.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	thread, err := s.Comments.GetThread(ctx, root.Id, author.Id, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := commentIds(thread.Replies), []int{newer.Id, older.Id}; !slices.Equal(got, want) {
+		t.Fatalf("replies = %v, want %v", got, want)
+	}
+	if got := commentIds(thread.Replies[0].Replies); len(got) != 0 {
+		t.Fatalf("deleted reply in the thread: %v", got)
+	}
+	if got, want := commentIds(thread.Replies[1].Replies), []int{nested.Id}; !slices.Equal(got, want) {
+		t.Fatalf("nested replies = %v, want %v", got, want)
+	}
+	if got := commentIds(thread.Replies[1].Replies[0].Replies); containsId(got, deeper.Id) {
+		t.Fatal("reply below the depth limit in the thread")
+	}
+
+	// A reply can be the root of a thread of its own.
+	sub, err := s.Comments.GetThread(ctx, older.Id, author.Id, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Id != older.Id || len(sub.Replies) != 1 || len(sub.Replies[0].Replies) != 1 {
+		t.Fatalf("thread of a reply = %+v", sub)
+	}
+}
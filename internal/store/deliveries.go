@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+// DeliveryStore tracks the side effects of publishing posts and reposts that are still to run.
+// Deliveries are enqueued in the transaction that publishes, so they survive the loss of the job
+// that runs them, and are completed by that job or, failing that, by a later sweep.
+type DeliveryStore struct {
+	db *sqlx.DB
+}
+
+func NewDeliveryStore(db *sql.DB) *DeliveryStore {
+	return &DeliveryStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// Delivery is pending work for a post or a repost; exactly one of PostId and RepostId is set.
+type Delivery struct {
+	Id       int  `db:"id"`
+	PostId   *int `db:"post_id"`
+	RepostId *int `db:"repost_id"`
+	Version  int  `db:"version"`
+}
+
+// enqueuePostDelivery records that postId is to be delivered, or delivered again if it already was
+// pending.
+func enqueuePostDelivery(ctx context.Context, tx sqlx.ExecerContext, postId int) error {
+	dml := `INSERT INTO deliveries (post_id) VALUES ($1)
+		ON CONFLICT (post_id) DO UPDATE SET version = deliveries.version + 1, claimed_at = NULL`
+	if _, err := tx.ExecContext(ctx, dml, postId); err != nil {
+		return fmt.Errorf("failed to enqueue delivery: %w", err)
+	}
+	return nil
+}
+
+// ClaimPost claims the pending delivery of postId, and ClaimRepost that of repostId. A delivery
+// can be claimed when it isn't claimed or its claim is older than stale; otherwise sql.ErrNoRows
+// is returned, so only one worker runs each delivery at a time.
+func (s *DeliveryStore) ClaimPost(ctx context.Context, postId int, stale time.Duration) (*Delivery, error) {
+	return s.claim(ctx, "post_id", postId, stale)
+}
+
+func (s *DeliveryStore) ClaimRepost(ctx context.Context, repostId int, stale time.Duration) (*Delivery, error) {
+	return s.claim(ctx, "repost_id", repostId, stale)
+}
+
+func (s *DeliveryStore) claim(ctx context.Context, column string, id int, stale time.Duration) (*Delivery, error) {
+	query := `UPDATE deliveries SET claimed_at = CURRENT_TIMESTAMP
+		WHERE ` + column + ` = $1
+			AND (claimed_at IS NULL OR claimed_at < CURRENT_TIMESTAMP - make_interval(secs => $2))
+		RETURNING id, post_id, repost_id, version`
+	var delivery Delivery
+	if err := s.db.GetContext(ctx, &delivery, query, id, stale.Seconds()); err != nil {
+		return nil, fmt.Errorf("failed to claim delivery: %w", err)
+	}
+	return &delivery, nil
+}
+
+// Complete deletes the delivery d once it has run, unless it was enqueued again meanwhile.
+func (s *DeliveryStore) Complete(ctx context.Context, d *Delivery) error {
+	dml := `DELETE FROM deliveries WHERE id = $1 AND version = $2`
+	if _, err := s.db.ExecContext(ctx, dml, d.Id, d.Version); err != nil {
+		return fmt.Errorf("failed to complete delivery: %w", err)
+	}
+	return nil
+}
+
+// ListDue returns up to limit deliveries that have been waiting, or been claimed, for longer
+// than stale, such as when their job was dropped or lost in a restart.
+func (s *DeliveryStore) ListDue(ctx context.Context, stale time.Duration, limit int) ([]Delivery, error) {
+	query := `SELECT id, post_id, repost_id, version FROM deliveries
+		WHERE COALESCE(claimed_at, created_at) < CURRENT_TIMESTAMP - make_interval(secs => $1)
+		ORDER BY COALESCE(claimed_at, created_at)
+		LIMIT $2`
+	var deliveries []Delivery
+	if err := s.db.SelectContext(ctx, &deliveries, query, stale.Seconds(), limit); err != nil {
+		return nil, fmt.Errorf("failed to list due deliveries: %w", err)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,283 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+var (
+	ErrSelfFollow = errors.New("users cannot follow themselves")
+	ErrBlocked    = errors.New("users cannot follow users they blocked or were blocked by")
+)
+
+type FollowStore struct {
+	db *sqlx.DB
+}
+
+func NewFollowStore(db *sql.DB) *FollowStore {
+	return &FollowStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// FollowEntry is one user in a followers or following list.
+type FollowEntry struct {
+	UserId     int       `db:"user_id" json:"-"`
+	User       Author    `db:"user" json:"user"`
+	FollowedAt time.Time `db:"followed_at" json:"followed_at"`
+}
+
+func (f FollowEntry) cursor() Cursor {
+	return Cursor{CreatedAt: f.FollowedAt, Id: f.UserId}
+}
+
+// Follow makes followerId follow followeeId. It reports false without error when the follow
+// already existed, and ErrBlocked when either blocked the other.
+func (s *FollowStore) Follow(ctx context.Context, followerId, followeeId int) (bool, error) {
+	return s.insertUnlessBlocked(ctx, "follows", followerId, followeeId)
+}
+
+// insertUnlessBlocked inserts followerId and followeeId into table, follows or follow_requests,
+// unless either blocked the other. It holds the locks of both users, so that a block being made
+// meanwhile can't miss the new row. It reports false without error when the row already existed.
+func (s *FollowStore) insertUnlessBlocked(ctx context.Context, table string, followerId, followeeId int) (bool, error) {
+	if followerId == followeeId {
+		return false, ErrSelfFollow
+	}
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockUsers(ctx, tx, followerId, followeeId); err != nil {
+		return false, err
+	}
+	dml := `WITH blocked AS (
+			SELECT ` + blockedBetween("$1", "$2") + ` AS blocked
+		), inserted AS (
+			INSERT INTO ` + table + ` (follower_id, followee_id) SELECT $1, $2 FROM blocked WHERE NOT blocked
+			ON CONFLICT DO NOTHING
+			RETURNING 1
+		)
+		SELECT blocked, EXISTS (SELECT 1 FROM inserted) FROM blocked`
+	var blocked, created bool
+	if err := tx.QueryRowContext(ctx, dml, followerId, followeeId).Scan(&blocked, &created); err != nil {
+		return false, fmt.Errorf("failed to insert into %s: %w", table, err)
+	}
+	if blocked {
+		return false, ErrBlocked
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit insert into %s: %w", table, err)
+	}
+	return created, nil
+}
+
+// Unfollow removes the follow, reporting false without error when there was none.
+func (s *FollowStore) Unfollow(ctx context.Context, followerId, followeeId int) (bool, error) {
+	dml := `DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2`
+	result, err := s.db.ExecContext(ctx, dml, followerId, followeeId)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete follow: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete follow: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (s *FollowStore) IsFollowing(ctx context.Context, followerId, followeeId int) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM follows WHERE follower_id = $1 AND followee_id = $2)`
+	var following bool
+	if err := s.db.GetContext(ctx, &following, query, followerId, followeeId); err != nil {
+		return false, fmt.Errorf("failed to query follow: %w", err)
+	}
+	return following, nil
+}
+
+// ListFollowers returns a page of the users following userId, most recent first.
+func (s *FollowStore) ListFollowers(ctx context.Context, userId int, page Page) ([]FollowEntry, *Cursor, error) {
+	keyset, args := page.where("f.created_at", "f.follower_id", []any{userId})
+	order, args := page.orderLimit("f.created_at", "f.follower_id", args)
+	query := `SELECT f.follower_id AS user_id, f.created_at AS followed_at,
+			u.public_id AS "user.id", u.username AS "user.username"
+		FROM follows f JOIN users u ON u.id = f.follower_id
+		WHERE f.followee_id = $1 AND ` + keyset + ` ` + order
+	var entries []FollowEntry
+	if err := s.db.SelectContext(ctx, &entries, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list followers: %w", err)
+	}
+	entries, next := paginate(entries, page.Limit, FollowEntry.cursor)
+	return entries, next, nil
+}
+
+// ListFollowing returns a page of the users userId follows, most recent first.
+func (s *FollowStore) ListFollowing(ctx context.Context, userId int, page Page) ([]FollowEntry, *Cursor, error) {
+	keyset, args := page.where("f.created_at", "f.followee_id", []any{userId})
+	order, args := page.orderLimit("f.created_at", "f.followee_id", args)
+	query := `SELECT f.followee_id AS user_id, f.created_at AS followed_at,
+			u.public_id AS "user.id", u.username AS "user.username"
+		FROM follows f JOIN users u ON u.id = f.followee_id
+		WHERE f.follower_id = $1 AND ` + keyset + ` ` + order
+	var entries []FollowEntry
+	if err := s.db.SelectContext(ctx, &entries, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list following: %w", err)
+	}
+	entries, next := paginate(entries, page.Limit, FollowEntry.cursor)
+	return entries, next, nil
+}
+
+// FollowRequest is a pending request to follow a private account.
+type FollowRequest struct {
+	UserId      int       `db:"user_id" json:"-"`
+	User        Author    `db:"user" json:"user"`
+	RequestedAt time.Time `db:"requested_at" json:"requested_at"`
+}
+
+func (f FollowRequest) cursor() Cursor {
+	return Cursor{CreatedAt: f.RequestedAt, Id: f.UserId}
+}
+
+// RequestFollow asks followeeId to let followerId follow them. It reports false without error
+// when the request was already pending, and ErrBlocked when either blocked the other.
+func (s *FollowStore) RequestFollow(ctx context.Context, followerId, followeeId int) (bool, error) {
+	return s.insertUnlessBlocked(ctx, "follow_requests", followerId, followeeId)
+}
+
+// requestAnswered deletes the follow request notifications of the requests in the CTE named
+// requests, once they are approved, rejected or withdrawn. Notifications are sent once per
+// actor and kind, so the one of an earlier request would keep a new request from notifying.
+const requestAnswered = `cleared AS (
+			DELETE FROM notifications n USING requests r
+			WHERE n.user_id = r.followee_id AND n.actor_id = r.follower_id AND n.kind = '` + NotificationFollowRequest + `'
+		)`
+
+// DeleteRequest removes the request of followerId to follow followeeId, for when it is
+// rejected or withdrawn. It reports false without error when there was none.
+func (s *FollowStore) DeleteRequest(ctx context.Context, followerId, followeeId int) (bool, error) {
+	dml := `WITH requests AS (
+			DELETE FROM follow_requests WHERE follower_id = $1 AND followee_id = $2
+			RETURNING follower_id, followee_id
+		), ` + requestAnswered + `
+		SELECT EXISTS (SELECT 1 FROM requests)`
+	var deleted bool
+	if err := s.db.GetContext(ctx, &deleted, dml, followerId, followeeId); err != nil {
+		return false, fmt.Errorf("failed to delete follow request: %w", err)
+	}
+	return deleted, nil
+}
+
+// ApproveRequest turns the request of followerId to follow followeeId into a follow. It reports
+// false without error when there was no such request, and true when there was one even if
+// followerId already followed followeeId. Like following, it holds the locks of both users, as a
+// block deletes the request.
+func (s *FollowStore) ApproveRequest(ctx context.Context, followerId, followeeId int) (bool, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockUsers(ctx, tx, followerId, followeeId); err != nil {
+		return false, err
+	}
+	dml := `WITH requests AS (
+			DELETE FROM follow_requests WHERE follower_id = $1 AND followee_id = $2
+			RETURNING follower_id, followee_id
+		), followed AS (
+			INSERT INTO follows (follower_id, followee_id) SELECT follower_id, followee_id FROM requests
+			ON CONFLICT DO NOTHING
+		), ` + requestAnswered + `
+		SELECT EXISTS (SELECT 1 FROM requests)`
+	var approved bool
+	if err := tx.GetContext(ctx, &approved, dml, followerId, followeeId); err != nil {
+		return false, fmt.Errorf("failed to approve follow request: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit follow request approval: %w", err)
+	}
+	return approved, nil
+}
+
+// ApproveAllRequests turns every pending request to follow followeeId into a follow, for when
+// the account stops being private, and returns the ids of the new followers. It holds the locks
+// of followeeId and of the users asking to follow them, and leaves the requests made meanwhile.
+func (s *FollowStore) ApproveAllRequests(ctx context.Context, followeeId int) ([]int, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var requesterIds []int
+	if err := tx.SelectContext(ctx, &requesterIds, `SELECT follower_id FROM follow_requests WHERE followee_id = $1`,
+		followeeId); err != nil {
+		return nil, fmt.Errorf("failed to list follow requests: %w", err)
+	}
+	if err := lockUsers(ctx, tx, append(requesterIds, followeeId)...); err != nil {
+		return nil, err
+	}
+	dml := `WITH requests AS (
+			DELETE FROM follow_requests WHERE followee_id = $1 AND follower_id = ANY($2)
+			RETURNING follower_id, followee_id
+		), ` + requestAnswered + `
+		INSERT INTO follows (follower_id, followee_id) SELECT follower_id, followee_id FROM requests
+		ON CONFLICT DO NOTHING
+		RETURNING follower_id`
+	var ids []int
+	if err := tx.SelectContext(ctx, &ids, dml, followeeId, pq.Array(requesterIds)); err != nil {
+		return nil, fmt.Errorf("failed to approve follow requests: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit follow request approvals: %w", err)
+	}
+	return ids, nil
+}
+
+func (s *FollowStore) HasRequested(ctx context.Context, followerId, followeeId int) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM follow_requests WHERE follower_id = $1 AND followee_id = $2)`
+	var requested bool
+	if err := s.db.GetContext(ctx, &requested, query, followerId, followeeId); err != nil {
+		return false, fmt.Errorf("failed to query follow request: %w", err)
+	}
+	return requested, nil
+}
+
+// ListRequests returns a page of the users asking to follow userId, most recent first.
+func (s *FollowStore) ListRequests(ctx context.Context, userId int, page Page) ([]FollowRequest, *Cursor, error) {
+	keyset, args := page.where("fr.created_at", "fr.follower_id", []any{userId})
+	order, args := page.orderLimit("fr.created_at", "fr.follower_id", args)
+	query := `SELECT fr.follower_id AS user_id, fr.created_at AS requested_at,
+			u.public_id AS "user.id", u.username AS "user.username"
+		FROM follow_requests fr JOIN users u ON u.id = fr.follower_id
+		WHERE fr.followee_id = $1 AND ` + keyset + ` ` + order
+	var requests []FollowRequest
+	if err := s.db.SelectContext(ctx, &requests, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list follow requests: %w", err)
+	}
+	requests, next := paginate(requests, page.Limit, FollowRequest.cursor)
+	return requests, next, nil
+}
+
+// ListSentRequests returns a page of the users userId asked to follow, most recent first.
+func (s *FollowStore) ListSentRequests(ctx context.Context, userId int, page Page) ([]FollowRequest, *Cursor, error) {
+	keyset, args := page.where("fr.created_at", "fr.followee_id", []any{userId})
+	order, args := page.orderLimit("fr.created_at", "fr.followee_id", args)
+	query := `SELECT fr.followee_id AS user_id, fr.created_at AS requested_at,
+			u.public_id AS "user.id", u.username AS "user.username"
+		FROM follow_requests fr JOIN users u ON u.id = fr.followee_id
+		WHERE fr.follower_id = $1 AND ` + keyset + ` ` + order
+	var requests []FollowRequest
+	if err := s.db.SelectContext(ctx, &requests, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list sent follow requests: %w", err)
+	}
+	requests, next := paginate(requests, page.Limit, FollowRequest.cursor)
+	return requests, next, nil
+}
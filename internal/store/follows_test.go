@@ -0,0 +1,206 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// followRequestNotifications returns how many follow request notifications user has from actor.
+func followRequestNotifications(t *testing.T, s *Store, user, actor *User) int {
+	t.Helper()
+	notifications, _, err := s.Notifications.List(context.Background(), user.Id, false, Page{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, notification := range notifications {
+		if notification.Kind == NotificationFollowRequest && notification.ActorId == actor.Id {
+			n++
+		}
+	}
+	return n
+}
+
+// requestFollow asks followee to let follower follow them and notifies followee, as the follow
+// handler does.
+func requestFollow(t *testing.T, s *Store, follower, followee *User) {
+	t.Helper()
+	ctx := context.Background()
+	created, err := s.Follows.RequestFollow(ctx, follower.Id, followee.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created {
+		t.Fatal("follow request already pending")
+	}
+	if err := s.Notifications.Create(ctx, NewNotification{
+		UserId: followee.Id, ActorId: follower.Id, Kind: NotificationFollowRequest,
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	follower := createUser(t, s, "follower")
+	followee := createUser(t, s, "followee")
+
+	if _, err := s.Follows.Follow(ctx, follower.Id, follower.Id); !errors.Is(err, ErrSelfFollow) {
+		t.Fatalf("following oneself: got %v, want %v", err, ErrSelfFollow)
+	}
+	if created, err := s.Follows.Follow(ctx, follower.Id, followee.Id); err != nil || !created {
+		t.Fatalf("following: %v, %v", created, err)
+	}
+	if created, err := s.Follows.Follow(ctx, follower.Id, followee.Id); err != nil || created {
+		t.Fatalf("following again: %v, %v", created, err)
+	}
+
+	counts := func() (int, int) {
+		t.Helper()
+		f, err := s.User.GetUserById(ctx, follower.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := s.User.GetUserById(ctx, followee.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f.FollowingCount, g.FollowerCount
+	}
+	if following, followers := counts(); following != 1 || followers != 1 {
+		t.Fatalf("counts after following = %d following, %d followers, want 1 and 1", following, followers)
+	}
+	followers, _, err := s.Follows.ListFollowers(ctx, followee.Id, Page{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(followers) != 1 || followers[0].UserId != follower.Id {
+		t.Fatalf("followers = %+v", followers)
+	}
+	following, _, err := s.Follows.ListFollowing(ctx, follower.Id, Page{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(following) != 1 || following[0].UserId != followee.Id {
+		t.Fatalf("following = %+v", following)
+	}
+
+	if removed, err := s.Follows.Unfollow(ctx, follower.Id, followee.Id); err != nil || !removed {
+		t.Fatalf("unfollowing: %v, %v", removed, err)
+	}
+	if removed, err := s.Follows.Unfollow(ctx, follower.Id, followee.Id); err != nil || removed {
+		t.Fatalf("unfollowing again: %v, %v", removed, err)
+	}
+	if following, followers := counts(); following != 0 || followers != 0 {
+		t.Fatalf("counts after unfollowing = %d following, %d followers, want 0 and 0", following, followers)
+	}
+}
+
+func TestApproveRequestOfFollower(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	follower := createUser(t, s, "follower")
+	followee := createUser(t, s, "followee")
+	requestFollow(t, s, follower, followee)
+	// The follow can exist already, such as when it was made before the account went private.
+	follow(t, s, follower, followee)
+
+	approved, err := s.Follows.ApproveRequest(ctx, follower.Id, followee.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !approved {
+		t.Fatal("approving a pending request of a follower reported no request")
+	}
+	if requested, err := s.Follows.HasRequested(ctx, follower.Id, followee.Id); err != nil || requested {
+		t.Fatalf("request still pending after approval: %v, %v", requested, err)
+	}
+	if n := followRequestNotifications(t, s, followee, follower); n != 0 {
+		t.Fatalf("%d follow request notifications left after approval", n)
+	}
+	approved, err = s.Follows.ApproveRequest(ctx, follower.Id, followee.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved {
+		t.Fatal("approved a request that was already approved")
+	}
+}
+
+func TestRequestAgainNotifiesAgain(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	follower := createUser(t, s, "follower")
+	followee := createUser(t, s, "followee")
+
+	// Rejected, or withdrawn, and then asked again.
+	requestFollow(t, s, follower, followee)
+	if deleted, err := s.Follows.DeleteRequest(ctx, follower.Id, followee.Id); err != nil || !deleted {
+		t.Fatalf("deleting the request: %v, %v", deleted, err)
+	}
+	if n := followRequestNotifications(t, s, followee, follower); n != 0 {
+		t.Fatalf("%d follow request notifications left after rejection", n)
+	}
+	requestFollow(t, s, follower, followee)
+	if n := followRequestNotifications(t, s, followee, follower); n != 1 {
+		t.Fatalf("%d follow request notifications for the new request, want 1", n)
+	}
+
+	// Approved along with every other request when the account stops being private.
+	ids, err := s.Follows.ApproveAllRequests(ctx, followee.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != follower.Id {
+		t.Fatalf("new followers = %v, want [%d]", ids, follower.Id)
+	}
+	if n := followRequestNotifications(t, s, followee, follower); n != 0 {
+		t.Fatalf("%d follow request notifications left after approval", n)
+	}
+}
+
+func TestGoingPrivateRemovesRepostsFromTimelines(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	reposter := createUser(t, s, "reposter")
+	reader := createUser(t, s, "reader")
+	fan := createUser(t, s, "fan")
+	follow(t, s, reader, reposter)
+	follow(t, s, fan, reposter)
+	follow(t, s, fan, author)
+
+	post := createPost(t, s, author, NewPost{})
+	repostId, err := s.Reposts.Repost(ctx, reposter.Id, post.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Timelines.FanOutRepost(ctx, repostId, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	private := true
+	if _, err := s.User.UpdateProfile(ctx, author.Id, nil, &private); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Timelines.RemoveHiddenReposts(ctx, author.Id); err != nil {
+		t.Fatal(err)
+	}
+	entries := func(user *User) int {
+		t.Helper()
+		var n int
+		if err := testDb.QueryRow(`SELECT COUNT(*) FROM home_timelines WHERE user_id = $1 AND post_id = $2`,
+			user.Id, post.Id).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := entries(reader); n != 0 {
+		t.Fatal("repost left in the timeline of a user who doesn't follow the private author")
+	}
+	if n := entries(fan); n != 1 {
+		t.Fatal("repost removed from the timeline of a follower of the private author")
+	}
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+type LinkPreviewStore struct {
+	db *sqlx.DB
+}
+
+func NewLinkPreviewStore(db *sql.DB) *LinkPreviewStore {
+	return &LinkPreviewStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// LinkPreview describes the page at URL, as linked from a post.
+type LinkPreview struct {
+	PostId      int     `db:"post_id" json:"-"`
+	URL         string  `db:"url" json:"url"`
+	Title       string  `db:"title" json:"title"`
+	Description *string `db:"description" json:"description,omitempty"`
+	ImageURL    *string `db:"image_url" json:"image_url,omitempty"`
+	SiteName    *string `db:"site_name" json:"site_name,omitempty"`
+}
+
+// SetPostLinks replaces the URLs linked from postId with urls, in order.
+func (s *LinkPreviewStore) SetPostLinks(ctx context.Context, postId int, urls []string) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM post_links WHERE post_id = $1`, postId); err != nil {
+		return fmt.Errorf("failed to delete post links: %w", err)
+	}
+	for i, u := range urls {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO post_links (post_id, url, position) VALUES ($1, $2, $3)`,
+			postId, u, i); err != nil {
+			return fmt.Errorf("failed to insert post link: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit post links: %w", err)
+	}
+	return nil
+}
+
+// Queue adds the links of postId that have no preview yet as pending, and returns all of its
+// links in order, for the caller to claim.
+func (s *LinkPreviewStore) Queue(ctx context.Context, postId int) ([]string, error) {
+	query := `WITH queued AS (
+			INSERT INTO link_previews (url) SELECT url FROM post_links WHERE post_id = $1
+			ON CONFLICT (url) DO NOTHING
+		)
+		SELECT url FROM post_links WHERE post_id = $1 ORDER BY position`
+	var urls []string
+	if err := s.db.SelectContext(ctx, &urls, query, postId); err != nil {
+		return nil, fmt.Errorf("failed to queue link previews: %w", err)
+	}
+	return urls, nil
+}
+
+// Claim reports whether the caller should fetch the preview of url: when it is pending or was
+// last fetched more than ttl ago, and isn't claimed by another caller. A claim that hasn't been
+// settled by Save or Fail within stale, such as when its worker stopped, can be taken over. A
+// preview being fetched again keeps its status, so a ready one is still served meanwhile.
+func (s *LinkPreviewStore) Claim(ctx context.Context, url string, ttl, stale time.Duration) (bool, error) {
+	dml := `UPDATE link_previews SET claimed_at = CURRENT_TIMESTAMP
+		WHERE url = $1
+			AND (claimed_at IS NULL OR claimed_at < CURRENT_TIMESTAMP - make_interval(secs => $3))
+			AND (status = 'pending' OR fetched_at < CURRENT_TIMESTAMP - make_interval(secs => $2))`
+	res, err := s.db.ExecContext(ctx, dml, url, ttl.Seconds(), stale.Seconds())
+	if err != nil {
+		return false, fmt.Errorf("failed to claim link preview: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ListPending returns up to limit URLs whose preview has been waiting to be fetched, or been
+// claimed, for longer than stale, such as when their job was dropped or lost in a restart.
+func (s *LinkPreviewStore) ListPending(ctx context.Context, stale time.Duration, limit int) ([]string, error) {
+	query := `SELECT url FROM link_previews
+		WHERE status = 'pending'
+			AND COALESCE(claimed_at, created_at) < CURRENT_TIMESTAMP - make_interval(secs => $1)
+		ORDER BY COALESCE(claimed_at, created_at)
+		LIMIT $2`
+	var urls []string
+	if err := s.db.SelectContext(ctx, &urls, query, stale.Seconds(), limit); err != nil {
+		return nil, fmt.Errorf("failed to list pending link previews: %w", err)
+	}
+	return urls, nil
+}
+
+// Save stores the fetched preview of url.
+func (s *LinkPreviewStore) Save(ctx context.Context, url, title, description, imageURL, siteName string) error {
+	dml := `UPDATE link_previews SET status = 'ready', title = $2, description = NULLIF($3, ''),
+			image_url = NULLIF($4, ''), site_name = NULLIF($5, ''), fetched_at = CURRENT_TIMESTAMP,
+			claimed_at = NULL
+		WHERE url = $1`
+	if _, err := s.db.ExecContext(ctx, dml, url, title, description, imageURL, siteName); err != nil {
+		return fmt.Errorf("failed to save link preview: %w", err)
+	}
+	return nil
+}
+
+// Fail records that url has no preview, so that it isn't fetched again until the ttl passes.
+func (s *LinkPreviewStore) Fail(ctx context.Context, url string) error {
+	dml := `UPDATE link_previews SET status = 'failed', fetched_at = CURRENT_TIMESTAMP, claimed_at = NULL
+		WHERE url = $1`
+	if _, err := s.db.ExecContext(ctx, dml, url); err != nil {
+		return fmt.Errorf("failed to mark link preview failed: %w", err)
+	}
+	return nil
+}
+
+// ForPosts returns the previews of the links in each of postIds that have been fetched, in the
+// order the links appear.
+func (s *LinkPreviewStore) ForPosts(ctx context.Context, postIds []int) (map[int][]LinkPreview, error) {
+	query := `SELECT pl.post_id, lp.url, lp.title, lp.description, lp.image_url, lp.site_name
+		FROM post_links pl JOIN link_previews lp ON lp.url = pl.url
+		WHERE pl.post_id = ANY($1) AND lp.status = 'ready'
+		ORDER BY pl.position`
+	var rows []LinkPreview
+	if err := s.db.SelectContext(ctx, &rows, query, pq.Array(postIds)); err != nil {
+		return nil, fmt.Errorf("failed to query link previews: %w", err)
+	}
+	previews := make(map[int][]LinkPreview)
+	for _, row := range rows {
+		previews[row.PostId] = append(previews[row.PostId], row)
+	}
+	return previews, nil
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestClaimLinkPreview(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	post := createPost(t, s, author, NewPost{})
+	url := "https://example.com/" + author.Username
+	if err := s.LinkPreviews.SetPostLinks(ctx, post.Id, []string{url}); err != nil {
+		t.Fatal(err)
+	}
+	urls, err := s.LinkPreviews.Queue(ctx, post.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 1 || urls[0] != url {
+		t.Fatalf("queued %v", urls)
+	}
+
+	claim := func() bool {
+		t.Helper()
+		claimed, err := s.LinkPreviews.Claim(ctx, url, time.Hour, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return claimed
+	}
+	if !claim() {
+		t.Fatal("pending preview not claimed")
+	}
+	if claim() {
+		t.Fatal("preview claimed twice")
+	}
+	// A claim whose worker stopped is taken over once it is stale.
+	if _, err := testDb.Exec(`UPDATE link_previews SET claimed_at = claimed_at - interval '2 minutes' WHERE url = $1`,
+		url); err != nil {
+		t.Fatal(err)
+	}
+	if !claim() {
+		t.Fatal("stale claim not taken over")
+	}
+
+	if err := s.LinkPreviews.Save(ctx, url, "Title", "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if claim() {
+		t.Fatal("fresh preview claimed")
+	}
+	// An expired preview is fetched again, and is still served meanwhile.
+	if _, err := testDb.Exec(`UPDATE link_previews SET fetched_at = fetched_at - interval '2 hours' WHERE url = $1`,
+		url); err != nil {
+		t.Fatal(err)
+	}
+	if !claim() {
+		t.Fatal("expired preview not claimed")
+	}
+	previews, err := s.LinkPreviews.ForPosts(ctx, []int{post.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(previews[post.Id]) != 1 || previews[post.Id][0].Title != "Title" {
+		t.Fatalf("previews during refresh: %+v", previews[post.Id])
+	}
+}
+
+func TestListPendingLinkPreviews(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	post := createPost(t, s, author, NewPost{})
+	url := "https://example.com/" + author.Username
+	if err := s.LinkPreviews.SetPostLinks(ctx, post.Id, []string{url}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.LinkPreviews.Queue(ctx, post.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	pending := func() bool {
+		t.Helper()
+		urls, err := s.LinkPreviews.ListPending(ctx, time.Minute, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return slices.Contains(urls, url)
+	}
+	if pending() {
+		t.Fatal("freshly queued preview listed")
+	}
+	// A preview whose job was dropped is listed once it has waited too long.
+	if _, err := testDb.Exec(`UPDATE link_previews SET created_at = created_at - interval '2 minutes' WHERE url = $1`,
+		url); err != nil {
+		t.Fatal(err)
+	}
+	if !pending() {
+		t.Fatal("dropped preview not listed")
+	}
+	if err := s.LinkPreviews.Fail(ctx, url); err != nil {
+		t.Fatal(err)
+	}
+	if pending() {
+		t.Fatal("failed preview listed")
+	}
+}
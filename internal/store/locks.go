@@ -0,0 +1,41 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"slices"
+)
+
+// Classes of the advisory locks taken by background jobs that must not run concurrently in
+// several processes. They are the first key of two-key locks, whose key space doesn't overlap
+// that of single-key locks.
+const (
+	lockTrending = iota + 1
+	lockPurge
+)
+
+// tryJobLock takes the transaction-level advisory lock of class on name, reporting false if
+// another transaction holds it.
+func tryJobLock(ctx context.Context, tx *sqlx.Tx, class int, name string) (bool, error) {
+	var locked bool
+	if err := tx.GetContext(ctx, &locked, `SELECT pg_try_advisory_xact_lock($1, hashtext($2))`, class, name); err != nil {
+		return false, fmt.Errorf("failed to take advisory lock: %w", err)
+	}
+	return locked, nil
+}
+
+// lockUsers takes the transaction-level advisory locks of the users ids, in order so that
+// transactions locking the same users can't deadlock. Blocking and following lock both users,
+// so a follow can't be made while a block between them is being made. User ids are the keys of
+// single-key locks.
+func lockUsers(ctx context.Context, tx *sqlx.Tx, ids ...int) error {
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	for _, id := range slices.Compact(ids) {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, id); err != nil {
+			return fmt.Errorf("failed to lock user: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+// ErrMediaUnavailable is returned when media to attach doesn't exist, belongs to someone else
+// or is already attached to another post.
+var ErrMediaUnavailable = errors.New("media is not available")
+
+type MediaStore struct {
+	db *sqlx.DB
+}
+
+func NewMediaStore(db *sql.DB) *MediaStore {
+	return &MediaStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+const (
+	MediaPending    = "pending"
+	MediaProcessing = "processing"
+	MediaReady      = "ready"
+	MediaFailed     = "failed"
+)
+
+// Media is an uploaded file. Its bytes are kept in blob storage under StorageKey.
+type Media struct {
+	Id          int        `db:"id" json:"-"`
+	PublicId    string     `db:"public_id" json:"id"`
+	UserId      int        `db:"user_id" json:"-"`
+	PostId      *int       `db:"post_id" json:"-"`
+	Position    *int       `db:"position" json:"-"`
+	StorageKey  string     `db:"storage_key" json:"-"`
+	ContentType string     `db:"content_type" json:"content_type"`
+	Size        int64      `db:"size" json:"size"`
+	Status      string     `db:"status" json:"status"`
+	ClaimedAt   *time.Time `db:"claimed_at" json:"-"`
+	// Width, Height and Blurhash are set once an image has been processed.
+	Width      *int             `db:"width" json:"width,omitempty"`
+	Height     *int             `db:"height" json:"height,omitempty"`
+	Blurhash   *string          `db:"blurhash" json:"blurhash,omitempty"`
+	CreatedAt  time.Time        `db:"created_at" json:"created_at"`
+	Thumbnails []MediaThumbnail `db:"-" json:"thumbnails,omitempty"`
+	// URL is a signed download URL, filled in when ready media is returned.
+	URL string `db:"-" json:"url,omitempty"`
+}
+
+// MediaThumbnail is a smaller copy of an image, kept in blob storage under StorageKey.
+type MediaThumbnail struct {
+	MediaId     int    `db:"media_id" json:"-"`
+	Name        string `db:"name" json:"name"`
+	StorageKey  string `db:"storage_key" json:"-"`
+	ContentType string `db:"content_type" json:"content_type"`
+	Width       int    `db:"width" json:"width"`
+	Height      int    `db:"height" json:"height"`
+	Size        int64  `db:"size" json:"size"`
+	URL         string `db:"-" json:"url"`
+}
+
+// ProcessedMedia is the outcome of processing an image, replacing what was uploaded.
+type ProcessedMedia struct {
+	ContentType string
+	Size        int64
+	Width       int
+	Height      int
+	Blurhash    string
+	Thumbnails  []MediaThumbnail
+}
+
+// Create stores an upload of userId whose bytes were put under storageKey. Uploads that need
+// processing start out pending.
+func (s *MediaStore) Create(ctx context.Context, userId int, storageKey, contentType string, size int64,
+	status string) (*Media, error) {
+	query := `INSERT INTO media (user_id, storage_key, content_type, size, status) VALUES ($1, $2, $3, $4, $5)
+		RETURNING *`
+	var media Media
+	if err := s.db.GetContext(ctx, &media, query, userId, storageKey, contentType, size, status); err != nil {
+		return nil, fmt.Errorf("failed to insert media: %w", err)
+	}
+	return &media, nil
+}
+
+func (s *MediaStore) GetThumbnail(ctx context.Context, mediaId int, name string) (*MediaThumbnail, error) {
+	query := `SELECT * FROM media_thumbnails WHERE media_id = $1 AND name = $2`
+	var thumbnail MediaThumbnail
+	if err := s.db.GetContext(ctx, &thumbnail, query, mediaId, name); err != nil {
+		return nil, fmt.Errorf("failed to query media thumbnail: %w", err)
+	}
+	return &thumbnail, nil
+}
+
+// Claim marks media id as being processed and returns it. Media that is pending, or whose
+// processing was claimed more than stale ago and never finished, can be claimed; otherwise
+// sql.ErrNoRows is returned, so only one worker processes each upload.
+func (s *MediaStore) Claim(ctx context.Context, id int, stale time.Duration) (*Media, error) {
+	query := `UPDATE media SET status = 'processing', claimed_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND (status = 'pending'
+			OR (status = 'processing' AND claimed_at < CURRENT_TIMESTAMP - make_interval(secs => $2)))
+		RETURNING *`
+	var media Media
+	if err := s.db.GetContext(ctx, &media, query, id, stale.Seconds()); err != nil {
+		return nil, fmt.Errorf("failed to claim media: %w", err)
+	}
+	return &media, nil
+}
+
+// ListUnprocessed returns the ids of up to limit media that have been waiting for processing, or
+// been processing, for longer than stale, such as when their job was lost in a restart.
+func (s *MediaStore) ListUnprocessed(ctx context.Context, stale time.Duration, limit int) ([]int, error) {
+	query := `SELECT id FROM media
+		WHERE status IN ('pending', 'processing')
+			AND COALESCE(claimed_at, created_at) < CURRENT_TIMESTAMP - make_interval(secs => $1)
+		ORDER BY created_at
+		LIMIT $2`
+	var ids []int
+	if err := s.db.SelectContext(ctx, &ids, query, stale.Seconds(), limit); err != nil {
+		return nil, fmt.Errorf("failed to list unprocessed media: %w", err)
+	}
+	return ids, nil
+}
+
+// Complete stores the outcome of processing media id and marks it ready.
+func (s *MediaStore) Complete(ctx context.Context, id int, processed ProcessedMedia) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, t := range processed.Thumbnails {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO media_thumbnails
+				(media_id, name, storage_key, content_type, width, height, size)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (media_id, name) DO UPDATE SET storage_key = EXCLUDED.storage_key,
+				content_type = EXCLUDED.content_type, width = EXCLUDED.width, height = EXCLUDED.height,
+				size = EXCLUDED.size`,
+			id, t.Name, t.StorageKey, t.ContentType, t.Width, t.Height, t.Size); err != nil {
+			return fmt.Errorf("failed to insert media thumbnail: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE media SET status = 'ready', content_type = $2, size = $3,
+			width = $4, height = $5, blurhash = $6
+		WHERE id = $1`, id, processed.ContentType, processed.Size, processed.Width, processed.Height,
+		processed.Blurhash); err != nil {
+		return fmt.Errorf("failed to complete media: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit media: %w", err)
+	}
+	return nil
+}
+
+// Fail marks media id as impossible to process. It is never served.
+func (s *MediaStore) Fail(ctx context.Context, id int) error {
+	if _, err := s.db.ExecContext(ctx, `UPDATE media SET status = 'failed' WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to mark media failed: %w", err)
+	}
+	return nil
+}
+
+func (s *MediaStore) GetByPublicId(ctx context.Context, publicId string) (*Media, error) {
+	query := `SELECT * FROM media WHERE public_id = $1`
+	var media Media
+	if err := s.db.GetContext(ctx, &media, query, publicId); err != nil {
+		return nil, fmt.Errorf("failed to query media by public id: %w", err)
+	}
+	return &media, nil
+}
+
+// setPostMedia makes publicIds, in that order, the media of postId within tx. The media must
+// have been uploaded by the author of the post and not be attached to another post, or
+// ErrMediaUnavailable is returned. Media no longer in the list is detached and later purged.
+func setPostMedia(ctx context.Context, tx *sqlx.Tx, postId int, publicIds []string) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE media SET post_id = NULL, position = NULL
+		WHERE post_id = $1 AND NOT public_id = ANY($2::text[])`, postId, pq.Array(publicIds)); err != nil {
+		return fmt.Errorf("failed to detach media: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, `UPDATE media SET post_id = $1, position = array_position($2::text[], public_id::text)
+		WHERE public_id = ANY($2::text[]) AND (post_id IS NULL OR post_id = $1)
+			AND user_id = (SELECT user_id FROM posts WHERE id = $1)`, postId, pq.Array(publicIds))
+	if err != nil {
+		return fmt.Errorf("failed to attach media: %w", err)
+	}
+	attached, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to attach media: %w", err)
+	}
+	if int(attached) != len(publicIds) {
+		return ErrMediaUnavailable
+	}
+	return nil
+}
+
+// ForPosts returns the media attached to each of postIds, in order, with their thumbnails from
+// smallest to largest.
+func (s *MediaStore) ForPosts(ctx context.Context, postIds []int) (map[int][]Media, error) {
+	query := `SELECT * FROM media WHERE post_id = ANY($1) ORDER BY position`
+	var rows []Media
+	if err := s.db.SelectContext(ctx, &rows, query, pq.Array(postIds)); err != nil {
+		return nil, fmt.Errorf("failed to query media: %w", err)
+	}
+	mediaIds := make([]int, len(rows))
+	for i, row := range rows {
+		mediaIds[i] = row.Id
+	}
+	var thumbnails []MediaThumbnail
+	if err := s.db.SelectContext(ctx, &thumbnails, `SELECT * FROM media_thumbnails WHERE media_id = ANY($1)
+		ORDER BY width * height`, pq.Array(mediaIds)); err != nil {
+		return nil, fmt.Errorf("failed to query media thumbnails: %w", err)
+	}
+	byMedia := make(map[int][]MediaThumbnail)
+	for _, t := range thumbnails {
+		byMedia[t.MediaId] = append(byMedia[t.MediaId], t)
+	}
+	media := make(map[int][]Media)
+	for _, row := range rows {
+		row.Thumbnails = byMedia[row.Id]
+		media[*row.PostId] = append(media[*row.PostId], row)
+	}
+	return media, nil
+}
+
+// ListUnattached returns up to limit media that were created before olderThan and aren't
+// attached to any post.
+func (s *MediaStore) ListUnattached(ctx context.Context, olderThan time.Time, limit int) ([]Media, error) {
+	query := `SELECT * FROM media WHERE post_id IS NULL AND created_at < $1 ORDER BY created_at LIMIT $2`
+	var media []Media
+	if err := s.db.SelectContext(ctx, &media, query, olderThan, limit); err != nil {
+		return nil, fmt.Errorf("failed to list unattached media: %w", err)
+	}
+	return media, nil
+}
+
+// Delete removes media id unless it has been attached to a post in the meantime, and returns
+// the storage keys of the blobs it and its thumbnails leave behind. It returns no keys when the
+// media was not deleted.
+func (s *MediaStore) Delete(ctx context.Context, id int) ([]string, error) {
+	query := `WITH deleted AS (
+			DELETE FROM media WHERE id = $1 AND post_id IS NULL RETURNING id, storage_key
+		)
+		SELECT storage_key FROM deleted
+		UNION ALL
+		SELECT t.storage_key FROM media_thumbnails t JOIN deleted d ON d.id = t.media_id`
+	var keys []string
+	if err := s.db.SelectContext(ctx, &keys, query, id); err != nil {
+		return nil, fmt.Errorf("failed to delete media: %w", err)
+	}
+	return keys, nil
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// MentionTarget selects the table holding mentions in one kind of content.
+type MentionTarget struct {
+	table  string
+	column string
+}
+
+var (
+	PostMentions    = MentionTarget{table: "post_mentions", column: "post_id"}
+	CommentMentions = MentionTarget{table: "comment_mentions", column: "comment_id"}
+)
+
+type MentionStore struct {
+	db *sqlx.DB
+}
+
+func NewMentionStore(db *sql.DB) *MentionStore {
+	return &MentionStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// ResolvedMention is an @mention whose username matched UserId. Start and End are character
+// offsets into the content, with End exclusive.
+type ResolvedMention struct {
+	UserId int
+	Start  int
+	End    int
+}
+
+// MentionEntity is a mention as returned with the content it appears in.
+type MentionEntity struct {
+	TargetId int    `db:"target_id" json:"-"`
+	UserId   int    `db:"user_id" json:"-"`
+	User     Author `db:"user" json:"user"`
+	Start    int    `db:"start_offset" json:"start"`
+	End      int    `db:"end_offset" json:"end"`
+}
+
+// SetMentions replaces the mentions stored for targetId with mentions.
+func (s *MentionStore) SetMentions(ctx context.Context, target MentionTarget, targetId int, mentions []ResolvedMention) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE %s = $1`, target.table, target.column),
+		targetId); err != nil {
+		return fmt.Errorf("failed to delete mentions: %w", err)
+	}
+	dml := fmt.Sprintf(`INSERT INTO %s (%s, user_id, start_offset, end_offset) VALUES ($1, $2, $3, $4)`,
+		target.table, target.column)
+	for _, m := range mentions {
+		if _, err := tx.ExecContext(ctx, dml, targetId, m.UserId, m.Start, m.End); err != nil {
+			return fmt.Errorf("failed to insert mention: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit mentions: %w", err)
+	}
+	return nil
+}
+
+// ForTargets returns the mentions in each of targetIds, in the order they appear.
+func (s *MentionStore) ForTargets(ctx context.Context, target MentionTarget, targetIds []int) (map[int][]MentionEntity, error) {
+	query := fmt.Sprintf(`SELECT m.%[2]s AS target_id, m.user_id, m.start_offset, m.end_offset,
+			u.public_id AS "user.id", u.username AS "user.username"
+		FROM %[1]s m JOIN users u ON u.id = m.user_id
+		WHERE m.%[2]s = ANY($1)
+		ORDER BY m.start_offset`, target.table, target.column)
+	var rows []MentionEntity
+	if err := s.db.SelectContext(ctx, &rows, query, pq.Array(targetIds)); err != nil {
+		return nil, fmt.Errorf("failed to query mentions: %w", err)
+	}
+	mentions := make(map[int][]MentionEntity)
+	for _, row := range rows {
+		mentions[row.TargetId] = append(mentions[row.TargetId], row)
+	}
+	return mentions, nil
+}
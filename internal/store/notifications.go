@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	NotificationMention = "mention"
+	NotificationRepost  = "repost"
+	NotificationQuote   = "quote"
+	// NotificationFollowRequest tells a private account that the actor asked to follow it.
+	NotificationFollowRequest = "follow_request"
+)
+
+type NotificationStore struct {
+	db *sqlx.DB
+}
+
+func NewNotificationStore(db *sql.DB) *NotificationStore {
+	return &NotificationStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// NewNotification tells UserId that ActorId did something of Kind to the post or comment.
+type NewNotification struct {
+	UserId    int
+	ActorId   int
+	Kind      string
+	PostId    *int
+	CommentId *int
+}
+
+type Notification struct {
+	Id              int        `db:"id" json:"-"`
+	PublicId        string     `db:"public_id" json:"id"`
+	UserId          int        `db:"user_id" json:"-"`
+	ActorId         int        `db:"actor_id" json:"-"`
+	Actor           Author     `db:"actor" json:"actor"`
+	Kind            string     `db:"kind" json:"kind"`
+	PostId          *int       `db:"post_id" json:"-"`
+	PostPublicId    *string    `db:"post_public_id" json:"post_id,omitempty"`
+	CommentId       *int       `db:"comment_id" json:"-"`
+	CommentPublicId *string    `db:"comment_public_id" json:"comment_id,omitempty"`
+	ReadAt          *time.Time `db:"read_at" json:"read_at"`
+	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
+}
+
+func (n Notification) cursor() Cursor {
+	return Cursor{CreatedAt: n.CreatedAt, Id: n.Id}
+}
+
+// Create stores the notification, doing nothing if the same one was already sent or either of
+// the user and the actor blocked the other.
+func (s *NotificationStore) Create(ctx context.Context, n NewNotification) error {
+	dml := `INSERT INTO notifications (user_id, actor_id, kind, post_id, comment_id)
+		SELECT $1::BIGINT, $2::BIGINT, $3, $4::BIGINT, $5::BIGINT WHERE NOT ` + blockedBetween("$1::BIGINT", "$2::BIGINT") + `
+		ON CONFLICT DO NOTHING`
+	if _, err := s.db.ExecContext(ctx, dml, n.UserId, n.ActorId, n.Kind, n.PostId, n.CommentId); err != nil {
+		return fmt.Errorf("failed to insert notification: %w", err)
+	}
+	return nil
+}
+
+// List returns a page of the notifications of userId, newest first, only unread ones if
+// unreadOnly is set. Notifications about posts userId may not see, or from users userId blocked,
+// was blocked by or muted, are left out.
+func (s *NotificationStore) List(ctx context.Context, userId int, unreadOnly bool, page Page) ([]Notification, *Cursor, error) {
+	keyset, args := page.where("n.created_at", "n.id", []any{userId, unreadOnly})
+	order, args := page.orderLimit("n.created_at", "n.id", args)
+	query := `SELECT n.*, p.public_id AS post_public_id, c.public_id AS comment_public_id,
+			a.public_id AS "actor.id", a.username AS "actor.username"
+		FROM notifications n
+		JOIN users a ON a.id = n.actor_id
+		LEFT JOIN posts p ON p.id = n.post_id
+		LEFT JOIN comments c ON c.id = n.comment_id
+		WHERE n.user_id = $1 AND (NOT $2 OR n.read_at IS NULL)
+			AND p.deleted_at IS NULL AND c.deleted_at IS NULL
+			AND (p.id IS NULL OR ` + visibleTo("p", "$1") + `)
+			AND NOT ` + hiddenFrom("n.actor_id", "$1") + ` AND ` + keyset + ` ` + order
+	var notifications []Notification
+	if err := s.db.SelectContext(ctx, &notifications, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list notifications: %w", err)
+	}
+	notifications, next := paginate(notifications, page.Limit, Notification.cursor)
+	return notifications, next, nil
+}
+
+// MarkRead marks the notifications of userId with the given public ids as read, or all of
+// them when publicIds is empty.
+func (s *NotificationStore) MarkRead(ctx context.Context, userId int, publicIds []string) error {
+	dml := `UPDATE notifications SET read_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND read_at IS NULL AND (cardinality($2::text[]) = 0 OR public_id = ANY($2))`
+	if publicIds == nil {
+		publicIds = []string{}
+	}
+	if _, err := s.db.ExecContext(ctx, dml, userId, pq.Array(publicIds)); err != nil {
+		return fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+	return nil
+}
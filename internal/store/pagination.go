@@ -0,0 +1,130 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks the last row of a page in a list ordered by (created_at, id) descending, or by
+// (rank, id) descending for search results.
+type Cursor struct {
+	CreatedAt time.Time
+	Id        int
+	Rank      float64
+}
+
+func (c Cursor) marshal() []byte {
+	raw := strconv.FormatInt(c.CreatedAt.UnixNano(), 10) + ":" + strconv.Itoa(c.Id) + ":" +
+		strconv.FormatFloat(c.Rank, 'g', -1, 64)
+	return []byte(raw)
+}
+
+func unmarshalCursor(raw []byte) (*Cursor, error) {
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCursor
+	}
+	unixNano, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	cursorId, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	rank, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{CreatedAt: time.Unix(0, unixNano), Id: cursorId, Rank: rank}, nil
+}
+
+// CursorCodec seals cursors for clients, so that the internal ids they hold can be neither read
+// nor forged.
+type CursorCodec struct {
+	aead cipher.AEAD
+}
+
+// NewCursorCodec returns a codec keyed by secret.
+func NewCursorCodec(secret string) *CursorCodec {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		panic(err) // unreachable: a SHA-256 sum is a valid AES-256 key
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &CursorCodec{aead: aead}
+}
+
+// Encode returns c encrypted and base64 encoded.
+func (cc *CursorCodec) Encode(c Cursor) string {
+	nonce := make([]byte, cc.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	sealed := cc.aead.Seal(nonce, nonce, c.marshal(), nil)
+	return base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+// Decode reverses Encode, returning ErrInvalidCursor for anything Encode didn't produce.
+func (cc *CursorCodec) Decode(s string) (*Cursor, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(sealed) < cc.aead.NonceSize() {
+		return nil, ErrInvalidCursor
+	}
+	nonce, ciphertext := sealed[:cc.aead.NonceSize()], sealed[cc.aead.NonceSize():]
+	raw, err := cc.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return unmarshalCursor(raw)
+}
+
+// Page requests up to Limit rows after Cursor, or the first page when Cursor is nil.
+type Page struct {
+	Cursor *Cursor
+	Limit  int
+}
+
+// where returns the keyset condition on timeCol and idCol, appending its parameters to args.
+func (p Page) where(timeCol, idCol string, args []any) (string, []any) {
+	if p.Cursor == nil {
+		return "TRUE", args
+	}
+	args = append(args, p.Cursor.CreatedAt, p.Cursor.Id)
+	return fmt.Sprintf("(%s, %s) < ($%d, $%d)", timeCol, idCol, len(args)-1, len(args)), args
+}
+
+// orderLimit returns the ordering for the keyset and a limit one past the page size, so that
+// paginate can tell whether another page follows.
+func (p Page) orderLimit(timeCol, idCol string, args []any) (string, []any) {
+	args = append(args, p.Limit+1)
+	return fmt.Sprintf("ORDER BY %s DESC, %s DESC LIMIT $%d", timeCol, idCol, len(args)), args
+}
+
+// paginate trims the extra row fetched by orderLimit and returns the cursor for the next page,
+// or nil when rows is the last page.
+func paginate[T any](rows []T, limit int, cursorOf func(T) Cursor) ([]T, *Cursor) {
+	if len(rows) <= limit {
+		if rows == nil {
+			rows = []T{}
+		}
+		return rows, nil
+	}
+	rows = rows[:limit]
+	next := cursorOf(rows[len(rows)-1])
+	return rows, &next
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursorCodecRoundTrip(t *testing.T) {
+	codec := NewCursorCodec("an example secret of sufficient length")
+	want := Cursor{CreatedAt: time.Unix(1700000000, 123456789), Id: 42, Rank: 0.25}
+
+	encoded := codec.Encode(want)
+	if strings.Contains(encoded, "42") {
+		t.Fatalf("encoded cursor %q leaks its id", encoded)
+	}
+	got, err := codec.Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.Id != want.Id || got.Rank != want.Rank {
+		t.Fatalf("Decode = %+v, want %+v", *got, want)
+	}
+	if codec.Encode(want) == encoded {
+		t.Fatal("encoding the same cursor twice gave the same string")
+	}
+}
+
+func TestCursorCodecRejectsForgeries(t *testing.T) {
+	codec := NewCursorCodec("an example secret of sufficient length")
+	other := NewCursorCodec("a different secret of sufficient length")
+	encoded := codec.Encode(Cursor{CreatedAt: time.Now(), Id: 7})
+
+	tampered := []byte(encoded)
+	if tampered[len(tampered)-1] == 'A' {
+		tampered[len(tampered)-1] = 'B'
+	} else {
+		tampered[len(tampered)-1] = 'A'
+	}
+	for name, s := range map[string]string{
+		"tampered":    string(tampered),
+		"other key":   other.Encode(Cursor{CreatedAt: time.Now(), Id: 7}),
+		"not base64":  "!!!",
+		"too short":   "AAAA",
+		"empty":       "",
+		"plain value": "MTcwMDAwMDAwMDAwMDAwMDAwMDo3OjA",
+	} {
+		if _, err := codec.Decode(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Decode(%s) error = %v, want ErrInvalidCursor", name, err)
+		}
+	}
+}
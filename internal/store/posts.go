@@ -5,7 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"strconv"
 	"time"
 )
 
@@ -19,23 +20,450 @@ func NewPostStore(db *sql.DB) *PostStore {
 	}
 }
 
+// Author is the public view of the user who wrote a post or comment.
+type Author struct {
+	Id       string `db:"id" json:"id"`
+	Username string `db:"username" json:"username"`
+}
+
 type Posts struct {
-	UserId    int       `db:"user_id"`
-	Id        int       `db:"id"`
-	Title     string    `db:"title"`
-	Content   string    `db:"content"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
+	UserId   int    `db:"user_id" json:"-"`
+	Id       int    `db:"id" json:"-"`
+	PublicId string `db:"public_id" json:"id"`
+	Author   Author `db:"author" json:"author"`
+	Title    string `db:"title" json:"title"`
+	Content  string `db:"content" json:"content"`
+	Format   string `db:"format" json:"format"`
+	// ContentHTML is the rendering of Markdown content; plain text posts have none.
+	ContentHTML  *string    `db:"content_html" json:"content_html,omitempty"`
+	Language     string     `db:"language" json:"language"`
+	Status       string     `db:"status" json:"status"`
+	Visibility   string     `db:"visibility" json:"visibility"`
+	PublishAt    *time.Time `db:"publish_at" json:"publish_at,omitempty"`
+	CommentCount int        `db:"comment_count" json:"comment_count"`
+	RepostCount  int        `db:"repost_count" json:"repost_count"`
+	QuoteCount   int        `db:"quote_count" json:"quote_count"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time  `db:"updated_at" json:"updated_at"`
+	// EditedAt is when the title or content of the published post last changed.
+	EditedAt *time.Time `db:"edited_at" json:"edited_at"`
+	Edited   bool       `db:"edited" json:"edited"`
+	// DeletedAt is only set on posts listed from the trash.
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+	QuoteOf   *int       `db:"quote_of" json:"-"`
+	// QuoteId is the id of the post this one quotes. Quote holds that post for as long as the
+	// viewer can see it, and is left out once it is deleted.
+	QuoteId   *string        `db:"quote_public_id" json:"quote_id,omitempty"`
+	Quote     *Posts         `db:"-" json:"quote,omitempty"`
+	Reactions map[string]int `db:"-" json:"reactions"`
+	// MyReactions holds the viewer's own reactions when there is a signed-in viewer.
+	MyReactions []string        `db:"-" json:"my_reactions,omitempty"`
+	Mentions    []MentionEntity `db:"-" json:"mentions"`
+	Media       []Media         `db:"-" json:"media"`
+	Links       []LinkPreview   `db:"-" json:"links"`
+	// Reposted and Bookmarked report whether the signed-in viewer reposted and bookmarked the post.
+	Reposted   bool `db:"-" json:"reposted"`
+	Bookmarked bool `db:"-" json:"bookmarked"`
+	// RepostedBy is set on timeline entries that are there because a user reposted the post.
+	RepostedBy *Reposter `db:"-" json:"reposted_by,omitempty"`
 }
 
-func (s *PostStore) CreatePost(ctx context.Context, title, content string) (*Posts, error) {
-	dml := `INSERT INTO posts (user_id, id, title, content) VALUES ($1, $2, $3, $4) RETURNING *`
-	var post Posts
+func (p Posts) cursor() Cursor {
+	return Cursor{CreatedAt: p.CreatedAt, Id: p.Id}
+}
+
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+)
+
+const (
+	VisibilityPublic    = "public"
+	VisibilityFollowers = "followers"
+	VisibilityMentioned = "mentioned"
+)
+
+const (
+	PostDraft     = "draft"
+	PostScheduled = "scheduled"
+	PostPublished = "published"
+)
+
+// NewPost holds the fields of a post chosen by its author.
+type NewPost struct {
+	Title   string
+	Content string
+	// Language is the text search configuration used to index the post.
+	Language string
+	Format   string
+	// ContentHTML is the rendering of Content when Format is markdown.
+	ContentHTML *string
+	Status      string
+	Visibility  string
+	// PublishAt is when a scheduled post is published.
+	PublishAt *time.Time
+	// QuoteOf is the post quoted by a new post. UpdatePost ignores it.
+	QuoteOf *int
+	// MediaIds are the public ids of the uploads attached to the post, in order. UpdatePost
+	// leaves the attachments alone when it is nil.
+	MediaIds []string
+}
+
+const postColumns = `p.id, p.public_id, p.user_id, p.title, p.content, p.format, p.content_html, p.language, p.status, p.visibility, p.publish_at,
+	p.created_at, p.updated_at, p.edited_at, p.edited_at IS NOT NULL AS edited, p.deleted_at, p.quote_of,
+	u.public_id AS "author.id", u.username AS "author.username",
+	(SELECT qp.public_id FROM posts qp WHERE qp.id = p.quote_of) AS quote_public_id,
+	(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.deleted_at IS NULL) AS comment_count,
+	(SELECT COUNT(*) FROM reposts rp WHERE rp.post_id = p.id) AS repost_count,
+	(SELECT COUNT(*) FROM posts qp WHERE qp.quote_of = p.id AND qp.status = 'published' AND qp.deleted_at IS NULL) AS quote_count`
+
+const postFrom = `FROM posts p JOIN users u ON u.id = p.user_id`
+
+const postSelect = `SELECT ` + postColumns + ` ` + postFrom
+
+// publishedPost returns the condition for the post aliased alias being published and not
+// deleted.
+func publishedPost(alias string) string {
+	return alias + `.status = 'published' AND ` + alias + `.deleted_at IS NULL`
+}
+
+// publicPost returns the condition for the post aliased alias being visible to everyone: public,
+// by an account that isn't private.
+func publicPost(alias string) string {
+	return `(` + alias + `.visibility = 'public'
+		AND NOT EXISTS (SELECT 1 FROM users vu WHERE vu.id = ` + alias + `.user_id AND vu.is_private))`
+}
+
+// visibleTo returns the condition for the visibility of the post aliased alias, and the privacy
+// of its author, letting viewer, a SQL expression for the id of a user, see it. Authors see all
+// their posts, followers the public and followers-only posts of private accounts too, and users
+// mentioned in a post see it whatever its visibility. No one sees the posts of a user they
+// blocked or who blocked them.
+func visibleTo(alias, viewer string) string {
+	return `(NOT ` + blockedBetween(alias+`.user_id`, viewer) + ` AND (` + publicPost(alias) + `
+		OR ` + alias + `.user_id = ` + viewer + `
+		OR ` + alias + `.visibility IN ('public', 'followers') AND EXISTS (
+			SELECT 1 FROM follows vf WHERE vf.follower_id = ` + viewer + ` AND vf.followee_id = ` + alias + `.user_id
+		)
+		OR EXISTS (SELECT 1 FROM post_mentions vm WHERE vm.post_id = ` + alias + `.id AND vm.user_id = ` + viewer + `)))`
+}
+
+// listedPost returns the condition for the post aliased alias appearing to viewer, a SQL
+// expression for the id of the viewing user, in feeds, timelines, searches and tag pages. Posts
+// by users viewer muted are left out. Every query reading posts on behalf of a user goes through
+// it or GetPostByPublicId.
+func listedPost(alias, viewer string) string {
+	return publishedPost(alias) + ` AND ` + visibleTo(alias, viewer) + ` AND NOT ` + mutedBy(viewer, alias+`.user_id`)
+}
+
+// CreatePost inserts a post by the user in ctx. It returns ErrMediaUnavailable if MediaIds names
+// media that can't be attached.
+func (s *PostStore) CreatePost(ctx context.Context, post NewPost) (*Posts, error) {
+	dml := `INSERT INTO posts (user_id, title, content, language, status, publish_at, format, content_html, quote_of, visibility)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
+	var postId int
 	userId := ctx.Value("user").(*User).Id
 
-	// TODO check why id is required in postgres
-	if err := s.db.GetContext(ctx, &post, dml, userId, 2, title, content); err != nil {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if err := tx.GetContext(ctx, &postId, dml, userId, post.Title, post.Content, post.Language, post.Status,
+		post.PublishAt, post.Format, post.ContentHTML, post.QuoteOf, post.Visibility); err != nil {
 		return nil, fmt.Errorf("failed to insert post: %w", err)
 	}
+	if len(post.MediaIds) > 0 {
+		if err := setPostMedia(ctx, tx, postId, post.MediaIds); err != nil {
+			return nil, err
+		}
+	}
+	if post.Status == PostPublished {
+		if err := enqueuePostDelivery(ctx, tx, postId); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit post: %w", err)
+	}
+	return s.GetPostById(ctx, postId)
+}
+
+// UpdatePost replaces the fields of post id. A post becoming published takes the current time
+// as its created_at, so that it appears at the top of feeds rather than where it was drafted.
+// A post becoming published, or changing visibility once published, is queued for delivery.
+// It returns ErrMediaUnavailable if MediaIds names media that can't be attached.
+func (s *PostStore) UpdatePost(ctx context.Context, id int, post NewPost) (*Posts, error) {
+	dml := `UPDATE posts SET title = $2, content = $3, language = $4, status = $5, publish_at = $6,
+			format = $7, content_html = $8, visibility = $9,
+			created_at = CASE WHEN status <> 'published' AND $5 = 'published' THEN CURRENT_TIMESTAMP ELSE created_at END
+		WHERE id = $1`
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	var was struct {
+		Status     string `db:"status"`
+		Visibility string `db:"visibility"`
+	}
+	if err := tx.GetContext(ctx, &was, `SELECT status, visibility FROM posts WHERE id = $1 FOR UPDATE`, id); err != nil {
+		return nil, fmt.Errorf("failed to lock post: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, dml, id, post.Title, post.Content, post.Language, post.Status,
+		post.PublishAt, post.Format, post.ContentHTML, post.Visibility); err != nil {
+		return nil, fmt.Errorf("failed to update post: %w", err)
+	}
+	if post.MediaIds != nil {
+		if err := setPostMedia(ctx, tx, id, post.MediaIds); err != nil {
+			return nil, err
+		}
+	}
+	if post.Status == PostPublished && (was.Status != PostPublished || post.Visibility != was.Visibility) {
+		if err := enqueuePostDelivery(ctx, tx, id); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit post: %w", err)
+	}
+	return s.GetPostById(ctx, id)
+}
+
+// DeletePost moves post id to the trash on behalf of deletedBy. It stays there, hidden from every
+// read, until it is restored or purged.
+func (s *PostStore) DeletePost(ctx context.Context, id, deletedBy int) error {
+	dml := `UPDATE posts SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $2 WHERE id = $1 AND deleted_at IS NULL`
+	if _, err := s.db.ExecContext(ctx, dml, id, deletedBy); err != nil {
+		return fmt.Errorf("failed to delete post: %w", err)
+	}
+	return nil
+}
+
+// RestorePost takes the post publicId out of the trash of userId if it was deleted less than
+// retention ago. It returns sql.ErrNoRows if there is no such post.
+func (s *PostStore) RestorePost(ctx context.Context, publicId string, userId int, retention time.Duration) (*Posts, error) {
+	dml := `UPDATE posts SET deleted_at = NULL, deleted_by = NULL
+		WHERE public_id = $1 AND user_id = $2 AND deleted_by = $2
+			AND deleted_at > CURRENT_TIMESTAMP - make_interval(secs => $3)
+		RETURNING id`
+	var id int
+	if err := s.db.GetContext(ctx, &id, dml, publicId, userId, retention.Seconds()); err != nil {
+		return nil, fmt.Errorf("failed to restore post: %w", err)
+	}
+	return s.GetPostById(ctx, id)
+}
+
+// ListTrash returns a page of the posts userId deleted and can still restore, most recently
+// deleted first.
+func (s *PostStore) ListTrash(ctx context.Context, userId int, retention time.Duration, page Page) ([]Posts, *Cursor, error) {
+	keyset, args := page.where("p.deleted_at", "p.id", []any{userId, retention.Seconds()})
+	order, args := page.orderLimit("p.deleted_at", "p.id", args)
+	query := postSelect + ` WHERE p.user_id = $1 AND p.deleted_by = $1
+		AND p.deleted_at > CURRENT_TIMESTAMP - make_interval(secs => $2) AND ` + keyset + ` ` + order
+	var posts []Posts
+	if err := s.db.SelectContext(ctx, &posts, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list trash: %w", err)
+	}
+	posts, next := paginate(posts, page.Limit, func(p Posts) Cursor {
+		return Cursor{CreatedAt: *p.DeletedAt, Id: p.Id}
+	})
+	return posts, next, nil
+}
+
+// PurgeDeleted permanently removes up to limit posts and comments that were deleted more than
+// retention ago, and returns how many rows it removed. It removes nothing while another process
+// is purging.
+func (s *PostStore) PurgeDeleted(ctx context.Context, retention time.Duration, limit int) (int, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if locked, err := tryJobLock(ctx, tx, lockPurge, "deleted"); err != nil || !locked {
+		return 0, err
+	}
+	var purged int64
+	for _, dml := range []string{
+		`DELETE FROM posts WHERE id IN (
+			SELECT id FROM posts WHERE deleted_at < CURRENT_TIMESTAMP - make_interval(secs => $1) LIMIT $2
+		)`,
+		`DELETE FROM comments WHERE id IN (
+			SELECT id FROM comments WHERE deleted_at < CURRENT_TIMESTAMP - make_interval(secs => $1) LIMIT $2
+		)`,
+	} {
+		res, err := tx.ExecContext(ctx, dml, retention.Seconds(), limit)
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge deleted posts: %w", err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to purge deleted posts: %w", err)
+		}
+		purged += n
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit purge: %w", err)
+	}
+	return int(purged), nil
+}
+
+func (s *PostStore) GetPostById(ctx context.Context, id int) (*Posts, error) {
+	query := postSelect + ` WHERE p.id = $1`
+	var post Posts
+	if err := s.db.GetContext(ctx, &post, query, id); err != nil {
+		return nil, fmt.Errorf("failed to query post by id: %w", err)
+	}
+	return &post, nil
+}
+
+// GetPostByPublicId returns the post if viewerId may see it: unpublished posts are only visible
+// to their author, deleted posts to no one, and the rest as their visibility allows. Posts by
+// users viewerId muted are still returned when asked for directly.
+func (s *PostStore) GetPostByPublicId(ctx context.Context, publicId string, viewerId int) (*Posts, error) {
+	query := postSelect + ` WHERE p.public_id = $1 AND p.deleted_at IS NULL
+		AND (` + publishedPost("p") + ` AND ` + visibleTo("p", "$2") + ` OR p.user_id = $2)`
+	var post Posts
+	if err := s.db.GetContext(ctx, &post, query, publicId, viewerId); err != nil {
+		return nil, fmt.Errorf("failed to query post by public id: %w", err)
+	}
+	return &post, nil
+}
+
+// GetAnyPostByPublicId returns the post whatever its status and visibility, unless it is deleted,
+// for moderators acting on posts they can't see.
+func (s *PostStore) GetAnyPostByPublicId(ctx context.Context, publicId string) (*Posts, error) {
+	query := postSelect + ` WHERE p.public_id = $1 AND p.deleted_at IS NULL`
+	var post Posts
+	if err := s.db.GetContext(ctx, &post, query, publicId); err != nil {
+		return nil, fmt.Errorf("failed to query post by public id: %w", err)
+	}
 	return &post, nil
 }
+
+// GetListedPostsByIds returns those of ids that are listed to viewerId, in no particular order.
+// It loads the posts quoted by other posts, which are left out once they are deleted or the
+// viewer may no longer see them.
+func (s *PostStore) GetListedPostsByIds(ctx context.Context, ids []int, viewerId int) ([]Posts, error) {
+	query := postSelect + ` WHERE p.id = ANY($1) AND ` + listedPost("p", "$2")
+	var posts []Posts
+	if err := s.db.SelectContext(ctx, &posts, query, pq.Array(ids), viewerId); err != nil {
+		return nil, fmt.Errorf("failed to query posts by id: %w", err)
+	}
+	return posts, nil
+}
+
+// ListPosts returns a page of all posts viewerId may see, newest first, and the cursor of the
+// next page.
+func (s *PostStore) ListPosts(ctx context.Context, viewerId int, page Page) ([]Posts, *Cursor, error) {
+	keyset, args := page.where("p.created_at", "p.id", []any{viewerId})
+	order, args := page.orderLimit("p.created_at", "p.id", args)
+	query := postSelect + ` WHERE ` + listedPost("p", "$1") + ` AND ` + keyset + ` ` + order
+	var posts []Posts
+	if err := s.db.SelectContext(ctx, &posts, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list posts: %w", err)
+	}
+	posts, next := paginate(posts, page.Limit, Posts.cursor)
+	return posts, next, nil
+}
+
+// ListPostsByUser returns a page of the posts written or reposted by userId that viewerId may
+// see, newest first by when they were written or reposted, and the cursor of the next page. A
+// post userId reposted of their own is listed once, when it was reposted.
+func (s *PostStore) ListPostsByUser(ctx context.Context, userId, viewerId int, page Page) ([]Posts, *Cursor, error) {
+	written, args := page.where("wp.created_at", "wp.id", []any{userId, viewerId})
+	reposted, args := page.where("r.created_at", "r.post_id", args)
+	order, args := page.orderLimit("e.created_at", "e.post_id", args)
+	limit := "$" + strconv.Itoa(len(args))
+	query := `WITH entries AS (
+			(
+				SELECT wp.id AS post_id, wp.created_at, NULL::BIGINT AS repost_id FROM posts wp
+				WHERE wp.user_id = $1 AND ` + listedPost("wp", "$2") + ` AND ` + written + `
+					AND NOT EXISTS (SELECT 1 FROM reposts sr WHERE sr.user_id = $1 AND sr.post_id = wp.id)
+				ORDER BY wp.created_at DESC, wp.id DESC
+				LIMIT ` + limit + `
+			)
+			UNION ALL
+			(
+				SELECT r.post_id, r.created_at, r.id FROM reposts r
+				JOIN posts rp ON rp.id = r.post_id
+				WHERE r.user_id = $1 AND ` + reposted + ` AND ` + listedPost("rp", "$2") + `
+					AND ` + shownRepost("r.id", "$2") + `
+				ORDER BY r.created_at DESC, r.post_id DESC
+				LIMIT ` + limit + `
+			)
+		)
+		` + entrySelect + ` ` + order
+	posts, next, err := selectEntries(ctx, s.db, query, args, page.Limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list posts by user: %w", err)
+	}
+	return posts, next, nil
+}
+
+// ListDrafts returns a page of the draft and scheduled posts of userId, newest first.
+func (s *PostStore) ListDrafts(ctx context.Context, userId int, page Page) ([]Posts, *Cursor, error) {
+	keyset, args := page.where("p.created_at", "p.id", []any{userId})
+	order, args := page.orderLimit("p.created_at", "p.id", args)
+	query := postSelect + ` WHERE p.user_id = $1 AND p.status <> 'published' AND p.deleted_at IS NULL AND ` + keyset + ` ` + order
+	var posts []Posts
+	if err := s.db.SelectContext(ctx, &posts, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list drafts: %w", err)
+	}
+	posts, next := paginate(posts, page.Limit, Posts.cursor)
+	return posts, next, nil
+}
+
+// PublishDue publishes up to limit scheduled posts whose publish_at has passed and returns their
+// ids. Rows are claimed with FOR UPDATE SKIP LOCKED and re-checked under the lock, so when
+// several processes run it at once each post is published, and returned, by exactly one of them.
+// The posts are queued for delivery by the same statement that publishes them.
+func (s *PostStore) PublishDue(ctx context.Context, limit int) ([]int, error) {
+	dml := `WITH published AS (
+			UPDATE posts SET status = 'published', created_at = CURRENT_TIMESTAMP
+			WHERE id IN (
+				SELECT id FROM posts
+				WHERE status = 'scheduled' AND publish_at <= CURRENT_TIMESTAMP AND deleted_at IS NULL
+				ORDER BY publish_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			) AND status = 'scheduled'
+			RETURNING id
+		), queued AS (
+			INSERT INTO deliveries (post_id) SELECT id FROM published
+			ON CONFLICT (post_id) DO UPDATE SET version = deliveries.version + 1, claimed_at = NULL
+		)
+		SELECT id FROM published`
+	var ids []int
+	if err := s.db.SelectContext(ctx, &ids, dml, limit); err != nil {
+		return nil, fmt.Errorf("failed to publish due posts: %w", err)
+	}
+	return ids, nil
+}
+
+// PostRevision is an earlier version of a post, replaced at CreatedAt.
+type PostRevision struct {
+	Id        int       `db:"id" json:"-"`
+	PostId    int       `db:"post_id" json:"-"`
+	Title     string    `db:"title" json:"title"`
+	Content   string    `db:"content" json:"content"`
+	CreatedAt time.Time `db:"created_at" json:"replaced_at"`
+}
+
+func (r PostRevision) cursor() Cursor {
+	return Cursor{CreatedAt: r.CreatedAt, Id: r.Id}
+}
+
+// ListRevisions returns a page of the earlier versions of postId, newest first.
+func (s *PostStore) ListRevisions(ctx context.Context, postId int, page Page) ([]PostRevision, *Cursor, error) {
+	keyset, args := page.where("created_at", "id", []any{postId})
+	order, args := page.orderLimit("created_at", "id", args)
+	query := `SELECT id, post_id, title, content, created_at FROM post_revisions
+		WHERE post_id = $1 AND ` + keyset + ` ` + order
+	var revisions []PostRevision
+	if err := s.db.SelectContext(ctx, &revisions, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list post revisions: %w", err)
+	}
+	revisions, next := paginate(revisions, page.Limit, PostRevision.cursor)
+	return revisions, next, nil
+}
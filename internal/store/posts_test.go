@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEditingPublishedPostRecordsRevision(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	update := func(post *Posts, title, content, status, visibility string) *Posts {
+		t.Helper()
+		updated, err := s.Posts.UpdatePost(ctx, post.Id, NewPost{
+			Title: title, Content: content, Language: "english", Status: status,
+			Format: FormatPlain, Visibility: visibility,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return updated
+	}
+	revisions := func(post *Posts) []PostRevision {
+		t.Helper()
+		revisions, _, err := s.Posts.ListRevisions(ctx, post.Id, Page{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return revisions
+	}
+
+	// Drafts are rewritten without keeping their earlier versions.
+	post := createPost(t, s, author, NewPost{Title: "first", Content: "one", Status: PostDraft})
+	post = update(post, "second", "two", PostDraft, VisibilityPublic)
+	if n := len(revisions(post)); n != 0 || post.EditedAt != nil {
+		t.Fatalf("editing a draft recorded %d revisions, edited at %v", n, post.EditedAt)
+	}
+
+	post = update(post, "second", "two", PostPublished, VisibilityPublic)
+	post = update(post, "third", "three", PostPublished, VisibilityPublic)
+	post = update(post, "third", "four", PostPublished, VisibilityPublic)
+	// Changes other than to the title and content aren't revisions.
+	post = update(post, "third", "four", PostPublished, VisibilityFollowers)
+
+	got := revisions(post)
+	if len(got) != 2 {
+		t.Fatalf("%d revisions, want 2: %+v", len(got), got)
+	}
+	if got[0].Title != "third" || got[0].Content != "three" || got[1].Title != "second" || got[1].Content != "two" {
+		t.Fatalf("revisions = %+v, want the replaced versions newest first", got)
+	}
+	if post.EditedAt == nil {
+		t.Fatal("edited post has no edit time")
+	}
+}
+
+func TestGetAnyPostByPublicId(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	moderator := createUser(t, s, "moderator")
+	post := createPost(t, s, author, NewPost{Visibility: VisibilityFollowers})
+
+	if _, err := s.Posts.GetPostByPublicId(ctx, post.PublicId, moderator.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("followers-only post loaded for a stranger: %v", err)
+	}
+	got, err := s.Posts.GetAnyPostByPublicId(ctx, post.PublicId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != post.Id {
+		t.Fatalf("got post %d, want %d", got.Id, post.Id)
+	}
+
+	if err := s.Posts.DeletePost(ctx, post.Id, moderator.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Posts.GetAnyPostByPublicId(ctx, post.PublicId); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("deleted post loaded: %v", err)
+	}
+}
+
+func TestPublicIdsSortByCreationTime(t *testing.T) {
+	s := newTestStore(t)
+	author := createUser(t, s, "author")
+	var ids []string
+	for range 5 {
+		ids = append(ids, createPost(t, s, author, NewPost{}).PublicId)
+		// ULIDs made within the same millisecond are ordered at random.
+		time.Sleep(2 * time.Millisecond)
+	}
+	if !slices.IsSorted(ids) {
+		t.Fatalf("public ids out of creation order: %v", ids)
+	}
+	if len(slices.Compact(slices.Clone(ids))) != len(ids) {
+		t.Fatalf("duplicate public ids: %v", ids)
+	}
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"slices"
+	"time"
+)
+
+// ReactionKinds are the reactions users can leave on posts and comments, matching the check
+// constraints on the reaction tables.
+var ReactionKinds = []string{"like", "heart", "laugh", "wow", "sad", "angry"}
+
+func IsReactionKind(kind string) bool {
+	return slices.Contains(ReactionKinds, kind)
+}
+
+// ReactionTarget selects the table holding reactions to one kind of content.
+type ReactionTarget struct {
+	table  string
+	column string
+}
+
+var (
+	PostReactions    = ReactionTarget{table: "post_reactions", column: "post_id"}
+	CommentReactions = ReactionTarget{table: "comment_reactions", column: "comment_id"}
+)
+
+type ReactionStore struct {
+	db *sqlx.DB
+}
+
+func NewReactionStore(db *sql.DB) *ReactionStore {
+	return &ReactionStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// Reactor is a user who reacted to a post or comment.
+type Reactor struct {
+	Id        int       `db:"id" json:"-"`
+	UserId    int       `db:"user_id" json:"-"`
+	User      Author    `db:"user" json:"user"`
+	Kind      string    `db:"kind" json:"kind"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+func (r Reactor) cursor() Cursor {
+	return Cursor{CreatedAt: r.CreatedAt, Id: r.Id}
+}
+
+// AddReaction records the reaction, doing nothing if the user already reacted with kind.
+func (s *ReactionStore) AddReaction(ctx context.Context, target ReactionTarget, targetId, userId int, kind string) error {
+	dml := fmt.Sprintf(`INSERT INTO %s (%s, user_id, kind) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+		target.table, target.column)
+	if _, err := s.db.ExecContext(ctx, dml, targetId, userId, kind); err != nil {
+		return fmt.Errorf("failed to insert reaction: %w", err)
+	}
+	return nil
+}
+
+// RemoveReaction deletes the reaction, doing nothing if the user hasn't reacted with kind.
+func (s *ReactionStore) RemoveReaction(ctx context.Context, target ReactionTarget, targetId, userId int, kind string) error {
+	dml := fmt.Sprintf(`DELETE FROM %s WHERE %s = $1 AND user_id = $2 AND kind = $3`, target.table, target.column)
+	if _, err := s.db.ExecContext(ctx, dml, targetId, userId, kind); err != nil {
+		return fmt.Errorf("failed to delete reaction: %w", err)
+	}
+	return nil
+}
+
+// ListReactors returns a page of the users who reacted to targetId, most recent first, limited
+// to reactions of kind unless it is empty. Users viewerId blocked, was blocked by or muted are
+// left out.
+func (s *ReactionStore) ListReactors(ctx context.Context, target ReactionTarget, targetId int, kind string, viewerId int, page Page) ([]Reactor, *Cursor, error) {
+	keyset, args := page.where("r.created_at", "r.id", []any{targetId, kind, viewerId})
+	order, args := page.orderLimit("r.created_at", "r.id", args)
+	query := fmt.Sprintf(`SELECT r.id, r.user_id, r.kind, r.created_at, u.public_id AS "user.id", u.username AS "user.username"
+		FROM %s r JOIN users u ON u.id = r.user_id
+		WHERE r.%s = $1 AND ($2 = '' OR r.kind = $2) AND NOT %s AND %s %s`,
+		target.table, target.column, hiddenFrom("r.user_id", "$3"), keyset, order)
+	var reactors []Reactor
+	if err := s.db.SelectContext(ctx, &reactors, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list reactors: %w", err)
+	}
+	reactors, next := paginate(reactors, page.Limit, Reactor.cursor)
+	return reactors, next, nil
+}
+
+// Counts returns the number of reactions of each kind for each of targetIds.
+func (s *ReactionStore) Counts(ctx context.Context, target ReactionTarget, targetIds []int) (map[int]map[string]int, error) {
+	query := fmt.Sprintf(`SELECT %[2]s AS target_id, kind, COUNT(*) AS count FROM %[1]s
+		WHERE %[2]s = ANY($1) GROUP BY %[2]s, kind`, target.table, target.column)
+	var rows []struct {
+		TargetId int    `db:"target_id"`
+		Kind     string `db:"kind"`
+		Count    int    `db:"count"`
+	}
+	if err := s.db.SelectContext(ctx, &rows, query, pq.Array(targetIds)); err != nil {
+		return nil, fmt.Errorf("failed to count reactions: %w", err)
+	}
+	counts := make(map[int]map[string]int)
+	for _, row := range rows {
+		if counts[row.TargetId] == nil {
+			counts[row.TargetId] = make(map[string]int)
+		}
+		counts[row.TargetId][row.Kind] = row.Count
+	}
+	return counts, nil
+}
+
+// UserReactions returns the kinds userId reacted with for each of targetIds.
+func (s *ReactionStore) UserReactions(ctx context.Context, target ReactionTarget, targetIds []int, userId int) (map[int][]string, error) {
+	query := fmt.Sprintf(`SELECT %[2]s AS target_id, kind FROM %[1]s
+		WHERE %[2]s = ANY($1) AND user_id = $2 ORDER BY created_at`, target.table, target.column)
+	var rows []struct {
+		TargetId int    `db:"target_id"`
+		Kind     string `db:"kind"`
+	}
+	if err := s.db.SelectContext(ctx, &rows, query, pq.Array(targetIds), userId); err != nil {
+		return nil, fmt.Errorf("failed to query user reactions: %w", err)
+	}
+	kinds := make(map[int][]string)
+	for _, row := range rows {
+		kinds[row.TargetId] = append(kinds[row.TargetId], row.Kind)
+	}
+	return kinds, nil
+}
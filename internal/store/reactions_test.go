@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestListReactorsPagesThroughTies(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	reactor := createUser(t, s, "reactor")
+	other := createUser(t, s, "other")
+	post := createPost(t, s, author, NewPost{})
+
+	for _, kind := range []string{"like", "heart", "laugh"} {
+		if err := s.Reactions.AddReaction(ctx, PostReactions, post.Id, reactor.Id, kind); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Reactions.AddReaction(ctx, PostReactions, post.Id, other.Id, "like"); err != nil {
+		t.Fatal(err)
+	}
+	// Give every reaction the same timestamp, so that only the tiebreaker orders them.
+	if _, err := testDb.Exec(`UPDATE post_reactions SET created_at = '2024-01-01' WHERE post_id = $1`, post.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	page := Page{Limit: 1}
+	for {
+		reactors, next, err := s.Reactions.ListReactors(ctx, PostReactions, post.Id, "", author.Id, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range reactors {
+			key := r.User.Username + ":" + r.Kind
+			if seen[key] {
+				t.Fatalf("reaction %s listed twice", key)
+			}
+			seen[key] = true
+		}
+		if next == nil {
+			break
+		}
+		page.Cursor = next
+	}
+	if len(seen) != 4 {
+		t.Fatalf("listed %d reactions, want 4: %v", len(seen), seen)
+	}
+}
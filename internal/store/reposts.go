@@ -0,0 +1,168 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+type RepostStore struct {
+	db *sqlx.DB
+}
+
+func NewRepostStore(db *sql.DB) *RepostStore {
+	return &RepostStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// Reposter is a user who reposted a post, and when.
+type Reposter struct {
+	UserId    int       `db:"user_id" json:"-"`
+	User      Author    `db:"user" json:"user"`
+	CreatedAt time.Time `db:"created_at" json:"reposted_at"`
+}
+
+func (r Reposter) cursor() Cursor {
+	return Cursor{CreatedAt: r.CreatedAt, Id: r.UserId}
+}
+
+// Repost makes userId repost postId, queueing the repost for delivery, and returns the id of the
+// repost. It returns 0 without error when userId had already reposted it.
+func (s *RepostStore) Repost(ctx context.Context, userId, postId int) (int, error) {
+	dml := `WITH repost AS (
+			INSERT INTO reposts (user_id, post_id) VALUES ($1, $2) ON CONFLICT DO NOTHING RETURNING id
+		), queued AS (
+			INSERT INTO deliveries (repost_id) SELECT id FROM repost
+		)
+		SELECT id FROM repost`
+	var id int
+	err := s.db.GetContext(ctx, &id, dml, userId, postId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert repost: %w", err)
+	}
+	return id, nil
+}
+
+// Undo removes the repost of postId by userId, and with it the timeline entries it created.
+// Timelines of the author and of followers of the author who may see the post get the entry of
+// the post itself back, which the repost's entry took the place of.
+func (s *RepostStore) Undo(ctx context.Context, userId, postId int) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE home_timelines ht
+		SET author_id = p.user_id, created_at = p.created_at, repost_id = NULL
+		FROM reposts r JOIN posts p ON p.id = r.post_id
+		WHERE r.user_id = $1 AND r.post_id = $2 AND ht.repost_id = r.id
+			AND (ht.user_id = p.user_id
+				OR EXISTS (SELECT 1 FROM follows f WHERE f.follower_id = ht.user_id AND f.followee_id = p.user_id)
+					AND `+visibleTo("p", "ht.user_id")+`)`, userId, postId); err != nil {
+		return fmt.Errorf("failed to restore timeline entries: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM reposts WHERE user_id = $1 AND post_id = $2`, userId, postId); err != nil {
+		return fmt.Errorf("failed to delete repost: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit repost removal: %w", err)
+	}
+	return nil
+}
+
+// ListReposters returns a page of the users who reposted postId, most recent first. Users
+// viewerId blocked, was blocked by or muted are left out.
+func (s *RepostStore) ListReposters(ctx context.Context, postId, viewerId int, page Page) ([]Reposter, *Cursor, error) {
+	keyset, args := page.where("r.created_at", "r.user_id", []any{postId, viewerId})
+	order, args := page.orderLimit("r.created_at", "r.user_id", args)
+	query := `SELECT r.user_id, r.created_at, u.public_id AS "user.id", u.username AS "user.username"
+		FROM reposts r JOIN users u ON u.id = r.user_id
+		WHERE r.post_id = $1 AND NOT ` + hiddenFrom("r.user_id", "$2") + ` AND ` + keyset + ` ` + order
+	var reposters []Reposter
+	if err := s.db.SelectContext(ctx, &reposters, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list reposters: %w", err)
+	}
+	reposters, next := paginate(reposters, page.Limit, Reposter.cursor)
+	return reposters, next, nil
+}
+
+// RepostedBy returns which of postIds userId reposted.
+func (s *RepostStore) RepostedBy(ctx context.Context, postIds []int, userId int) (map[int]bool, error) {
+	query := `SELECT post_id FROM reposts WHERE post_id = ANY($1) AND user_id = $2`
+	var ids []int
+	if err := s.db.SelectContext(ctx, &ids, query, pq.Array(postIds), userId); err != nil {
+		return nil, fmt.Errorf("failed to query reposts: %w", err)
+	}
+	reposted := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		reposted[id] = true
+	}
+	return reposted, nil
+}
+
+// entrySelect selects the posts of the entries in a CTE named entries, with columns post_id,
+// created_at and repost_id, along with who reposted the post for entries that are reposts.
+// Entries are how lists ordered by when posts were added to them, rather than by when the
+// posts were created, are paginated.
+const entrySelect = `SELECT ` + postColumns + `, e.created_at AS entry_at,
+		eu.public_id AS reposter_id, eu.username AS reposter_username, er.user_id AS reposter_user_id
+	FROM entries e
+	JOIN posts p ON p.id = e.post_id
+	JOIN users u ON u.id = p.user_id
+	LEFT JOIN reposts er ON er.id = e.repost_id
+	LEFT JOIN users eu ON eu.id = er.user_id`
+
+// shownRepost returns the condition for the repost repostId, a SQL expression for the id of a
+// repost or NULL for entries that aren't reposts, not being hidden from viewer by a block or mute
+// of the reposter.
+func shownRepost(repostId, viewer string) string {
+	return `(` + repostId + ` IS NULL OR NOT EXISTS (
+		SELECT 1 FROM reposts vr WHERE vr.id = ` + repostId + ` AND ` + hiddenFrom("vr.user_id", viewer) + `
+	))`
+}
+
+// entry is a row of entrySelect.
+type entry struct {
+	Posts
+	EntryAt          time.Time `db:"entry_at"`
+	ReposterId       *string   `db:"reposter_id"`
+	ReposterUsername *string   `db:"reposter_username"`
+	ReposterUserId   *int      `db:"reposter_user_id"`
+}
+
+// cursor orders entries by when they were added, which for reposts and bookmarks is not when
+// the post was created.
+func (e entry) cursor() Cursor {
+	return Cursor{CreatedAt: e.EntryAt, Id: e.Id}
+}
+
+// selectEntries runs a query built on entrySelect and returns a page of its posts, with
+// RepostedBy set on those that are reposts.
+func selectEntries(ctx context.Context, db *sqlx.DB, query string, args []any, limit int) ([]Posts, *Cursor, error) {
+	var entries []entry
+	if err := db.SelectContext(ctx, &entries, query, args...); err != nil {
+		return nil, nil, err
+	}
+	entries, next := paginate(entries, limit, entry.cursor)
+	posts := make([]Posts, len(entries))
+	for i, e := range entries {
+		posts[i] = e.Posts
+		if e.ReposterUserId != nil {
+			posts[i].RepostedBy = &Reposter{
+				UserId:    *e.ReposterUserId,
+				User:      Author{Id: *e.ReposterId, Username: *e.ReposterUsername},
+				CreatedAt: e.EntryAt,
+			}
+		}
+	}
+	return posts, next, nil
+}
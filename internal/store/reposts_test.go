@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRepost(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	reposter := createUser(t, s, "reposter")
+	post := createPost(t, s, author, NewPost{})
+
+	repostId, err := s.Reposts.Repost(ctx, reposter.Id, post.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repostId == 0 {
+		t.Fatal("repost reported as a duplicate")
+	}
+	if again, err := s.Reposts.Repost(ctx, reposter.Id, post.Id); err != nil || again != 0 {
+		t.Fatalf("reposting again: %d, %v", again, err)
+	}
+	got, err := s.Posts.GetPostById(ctx, post.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.RepostCount != 1 {
+		t.Fatalf("repost count = %d, want 1", got.RepostCount)
+	}
+	reposted, err := s.Reposts.RepostedBy(ctx, []int{post.Id}, reposter.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reposted[post.Id] {
+		t.Fatal("repost not reported for the reposter")
+	}
+
+	// The repost is listed on the reposter's profile, by the reposter.
+	posts, _, err := s.Posts.ListPostsByUser(ctx, reposter.Id, author.Id, Page{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || posts[0].Id != post.Id || posts[0].RepostedBy == nil || posts[0].RepostedBy.UserId != reposter.Id {
+		t.Fatalf("reposter's posts = %+v", posts)
+	}
+
+	if err := s.Reposts.Undo(ctx, reposter.Id, post.Id); err != nil {
+		t.Fatal(err)
+	}
+	posts, _, err = s.Posts.ListPostsByUser(ctx, reposter.Id, author.Id, Page{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 0 {
+		t.Fatalf("undone repost listed: %+v", posts)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	quoter := createUser(t, s, "quoter")
+	post := createPost(t, s, author, NewPost{})
+	quote := createPost(t, s, quoter, NewPost{QuoteOf: &post.Id})
+	createPost(t, s, quoter, NewPost{QuoteOf: &post.Id, Status: PostDraft})
+
+	if quote.QuoteId == nil || *quote.QuoteId != post.PublicId {
+		t.Fatalf("quote id = %v, want %s", quote.QuoteId, post.PublicId)
+	}
+	got, err := s.Posts.GetPostById(ctx, post.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Drafts don't count.
+	if got.QuoteCount != 1 {
+		t.Fatalf("quote count = %d, want 1", got.QuoteCount)
+	}
+
+	// The quoted post is embedded for as long as it is there.
+	embedded, err := s.Posts.GetListedPostsByIds(ctx, []int{*quote.QuoteOf}, quoter.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(embedded) != 1 || embedded[0].Id != post.Id {
+		t.Fatalf("embedded posts = %+v", embedded)
+	}
+	if err := s.Posts.DeletePost(ctx, post.Id, author.Id); err != nil {
+		t.Fatal(err)
+	}
+	embedded, err = s.Posts.GetListedPostsByIds(ctx, []int{*quote.QuoteOf}, quoter.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(embedded) != 0 {
+		t.Fatalf("deleted post embedded: %+v", embedded)
+	}
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SearchLanguages are the text search configurations that ship with Postgres.
+var SearchLanguages = []string{
+	"simple", "arabic", "armenian", "basque", "catalan", "danish", "dutch", "english", "finnish",
+	"french", "german", "greek", "hindi", "hungarian", "indonesian", "irish", "italian",
+	"lithuanian", "nepali", "norwegian", "portuguese", "romanian", "russian", "serbian",
+	"spanish", "swedish", "tamil", "turkish", "yiddish",
+}
+
+func IsSearchLanguage(language string) bool {
+	return slices.Contains(SearchLanguages, language)
+}
+
+type SearchStore struct {
+	db *sqlx.DB
+}
+
+func NewSearchStore(db *sql.DB) *SearchStore {
+	return &SearchStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// PostSearchResult is a post matching a search with an excerpt of each matching field. The
+// excerpts are HTML-escaped, with the matched terms wrapped in <mark> elements.
+type PostSearchResult struct {
+	Posts
+	Rank           float64 `db:"rank" json:"-"`
+	TitleSnippet   string  `db:"title_snippet" json:"title_snippet"`
+	ContentSnippet string  `db:"content_snippet" json:"content_snippet"`
+}
+
+func (r PostSearchResult) cursor() Cursor {
+	return Cursor{Rank: r.Rank, Id: r.Id}
+}
+
+// escapeHTML is the SQL for HTML-escaping a text column before ts_headline marks it up.
+func escapeHTML(column string) string {
+	return fmt.Sprintf(`replace(replace(replace(%s, '&', '&amp;'), '<', '&lt;'), '>', '&gt;')`, column)
+}
+
+// SearchPosts returns a page of the posts matching the web-search style query q, parsed with
+// the language text search configuration, best match first.
+func (s *SearchStore) SearchPosts(ctx context.Context, q, language string, viewerId int, page Page) ([]PostSearchResult, *Cursor, error) {
+	args := []any{q, language, viewerId}
+	keyset := "TRUE"
+	if page.Cursor != nil {
+		args = append(args, page.Cursor.Rank, page.Cursor.Id)
+		keyset = fmt.Sprintf("(ranked.rank, ranked.id) < ($%d::real, $%d)", len(args)-1, len(args))
+	}
+	args = append(args, page.Limit+1)
+	headline := `'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10'`
+	tsquery := `websearch_to_tsquery($2::regconfig, $1)`
+	query := `SELECT ranked.*,
+			ts_headline(ranked.language, ` + escapeHTML("ranked.title") + `, ` + tsquery + `, ` + headline + `) AS title_snippet,
+			ts_headline(ranked.language, ` + escapeHTML("ranked.content") + `, ` + tsquery + `, ` + headline + `) AS content_snippet
+		FROM (
+			SELECT ` + postColumns + `, ts_rank_cd(p.search_vector, query) AS rank
+			` + postFrom + `, ` + tsquery + ` query
+			WHERE p.search_vector @@ query AND ` + listedPost("p", "$3") + `
+		) ranked
+		WHERE ` + keyset + `
+		ORDER BY ranked.rank DESC, ranked.id DESC
+		LIMIT $` + strconv.Itoa(len(args))
+	var results []PostSearchResult
+	if err := s.db.SelectContext(ctx, &results, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to search posts: %w", err)
+	}
+	results, next := paginate(results, page.Limit, PostSearchResult.cursor)
+	return results, next, nil
+}
+
+// UserSearchResult is a user matching a search, with whether the searching user follows them.
+type UserSearchResult struct {
+	User
+	Following bool `db:"following" json:"following"`
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchUsers returns up to limit users whose username or display name starts with or is
+// similar to q. Exact username matches rank first, then prefix matches, then accounts viewerId
+// follows, then accounts with more followers. Users viewerId blocked or was blocked by are left
+// out. Both the prefix and the similarity conditions are served by the trigram indexes.
+func (s *SearchStore) SearchUsers(ctx context.Context, q string, viewerId, limit int) ([]UserSearchResult, error) {
+	query := `SELECT u.*,
+			EXISTS (SELECT 1 FROM follows f WHERE f.follower_id = $3 AND f.followee_id = u.id) AS following
+		FROM users u
+		WHERE (u.username ILIKE $2 OR u.display_name ILIKE $2 OR u.username % $1 OR u.display_name % $1)
+			AND NOT ` + blockedBetween("u.id", "$3") + `
+		ORDER BY lower(u.username) = lower($1) DESC,
+			(u.username ILIKE $2 OR u.display_name ILIKE $2) DESC,
+			following DESC,
+			u.follower_count DESC,
+			greatest(similarity(u.username, $1), similarity(u.display_name, $1)) DESC,
+			u.id
+		LIMIT $4`
+	prefix := likeEscaper.Replace(q) + "%"
+	var results []UserSearchResult
+	if err := s.db.SelectContext(ctx, &results, query, q, prefix, viewerId, limit); err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	if results == nil {
+		results = []UserSearchResult{}
+	}
+	return results, nil
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSearchPosts(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	// A word no other test's posts have.
+	word := "quasar" + author.Username
+
+	inContent := createPost(t, s, author, NewPost{Content: "about " + word + " and how it runs"})
+	inTitle := createPost(t, s, author, NewPost{Title: word, Content: "<b>" + word + "</b>"})
+	createPost(t, s, author, NewPost{Content: "nothing to see"})
+	createPost(t, s, author, NewPost{Content: word, Status: PostDraft})
+	deleted := createPost(t, s, author, NewPost{Content: word})
+	if err := s.Posts.DeletePost(ctx, deleted.Id, author.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(q string, limit int) []PostSearchResult {
+		t.Helper()
+		var results []PostSearchResult
+		page := Page{Limit: limit}
+		for {
+			found, next, err := s.Search.SearchPosts(ctx, q, "english", author.Id, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results = append(results, found...)
+			if next == nil {
+				return results
+			}
+			page.Cursor = next
+		}
+	}
+
+	// Title matches weigh more than content matches, and paging by rank lists each once.
+	results := search(word, 1)
+	ids := make([]int, len(results))
+	for i, r := range results {
+		ids[i] = r.Id
+	}
+	if want := []int{inTitle.Id, inContent.Id}; !slices.Equal(ids, want) {
+		t.Fatalf("results = %v, want %v", ids, want)
+	}
+	title := results[0]
+	if !strings.Contains(title.TitleSnippet, "<mark>") || strings.Contains(title.ContentSnippet, "<b>") {
+		t.Fatalf("snippets = %q, %q; want marked matches and escaped HTML", title.TitleSnippet, title.ContentSnippet)
+	}
+
+	// Words are matched by their stem, and every word of the query must match.
+	if results := search(word+" running", 10); len(results) != 1 || results[0].Id != inContent.Id {
+		t.Fatalf("stemmed search = %+v", results)
+	}
+	if results := search(word+" -runs", 10); len(results) != 1 || results[0].Id != inTitle.Id {
+		t.Fatalf("search excluding a word = %+v", results)
+	}
+}
+
+func TestSearchUsersRanking(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	viewer := createUser(t, s, "viewer")
+	exact := createUser(t, s, "kiwi")
+	q := exact.Username
+	plain := createUser(t, s, q+"x")
+	popular := createUser(t, s, q+"x")
+	followed := createUser(t, s, q+"x")
+	blocker := createUser(t, s, q+"x")
+	fan := createUser(t, s, "fan")
+	name := q + " fan"
+	if _, err := s.User.UpdateProfile(ctx, fan.Id, &name, nil); err != nil {
+		t.Fatal(err)
+	}
+	follow(t, s, viewer, followed)
+	follow(t, s, createUser(t, s, "other"), popular)
+	follow(t, s, createUser(t, s, "other"), popular)
+	if _, err := s.Blocks.Block(ctx, blocker.Id, viewer.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := s.Search.SearchUsers(ctx, q, viewer.Id, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ours := []int{exact.Id, plain.Id, popular.Id, followed.Id, blocker.Id, fan.Id}
+	var got []int
+	for _, r := range results {
+		if containsId(ours, r.Id) {
+			got = append(got, r.Id)
+		}
+		if r.Id == followed.Id && !r.Following {
+			t.Error("followed user not marked as followed")
+		}
+	}
+	// The exact match, then prefix matches by whether the viewer follows them and by followers.
+	first := []int{exact.Id, followed.Id, popular.Id}
+	if len(got) != 5 || !slices.Equal(got[:3], first) {
+		t.Fatalf("results = %v, want %v first", got, first)
+	}
+	if rest := got[3:]; !containsId(rest, plain.Id) || !containsId(rest, fan.Id) {
+		t.Fatalf("results = %v, want %d and %d, which match by username and display name, last",
+			got, plain.Id, fan.Id)
+	}
+}
@@ -3,15 +3,43 @@ package store
 import "database/sql"
 
 type Store struct {
-	User    *UsersStore
-	Refresh *RefreshTokenStore
-	Posts   *PostStore
+	User          *UsersStore
+	Refresh       *RefreshTokenStore
+	Posts         *PostStore
+	Comments      *CommentStore
+	Reactions     *ReactionStore
+	Follows       *FollowStore
+	Timelines     *TimelineStore
+	Search        *SearchStore
+	Tags          *TagStore
+	Mentions      *MentionStore
+	Notifications *NotificationStore
+	Media         *MediaStore
+	LinkPreviews  *LinkPreviewStore
+	Reposts       *RepostStore
+	Bookmarks     *BookmarkStore
+	Blocks        *BlockStore
+	Deliveries    *DeliveryStore
 }
 
 func NewStore(db *sql.DB) *Store {
 	return &Store{
-		User:    NewUsersStore(db),
-		Refresh: NewRefreshTokenStore(db),
-		Posts:   NewPostStore(db),
+		User:          NewUsersStore(db),
+		Refresh:       NewRefreshTokenStore(db),
+		Posts:         NewPostStore(db),
+		Comments:      NewCommentStore(db),
+		Reactions:     NewReactionStore(db),
+		Follows:       NewFollowStore(db),
+		Timelines:     NewTimelineStore(db),
+		Search:        NewSearchStore(db),
+		Tags:          NewTagStore(db),
+		Mentions:      NewMentionStore(db),
+		Notifications: NewNotificationStore(db),
+		Media:         NewMediaStore(db),
+		LinkPreviews:  NewLinkPreviewStore(db),
+		Reposts:       NewRepostStore(db),
+		Bookmarks:     NewBookmarkStore(db),
+		Blocks:        NewBlockStore(db),
+		Deliveries:    NewDeliveryStore(db),
 	}
 }
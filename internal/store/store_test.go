@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/caarlos0/env/v11"
+	"github.com/cappstr/GopherSocial/internal/config"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testDb is a database migrated into a schema of its own for this test run, or nil when the
+// tests aren't run with ENV=dev against the test database.
+var testDb *sql.DB
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	cfg, err := env.ParseAs[config.Config]()
+	if err != nil || !cfg.IsDev() {
+		return m.Run()
+	}
+	db, drop, err := openTestDb(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	defer drop()
+	testDb = db
+	return m.Run()
+}
+
+// openTestDb creates a schema on the test database, applies the migrations to it and returns a
+// connection pool that uses it, along with a function dropping the schema again.
+func openTestDb(cfg config.Config) (*sql.DB, func(), error) {
+	admin, err := NewPostgresDb(&cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	schema := "test_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		admin.Close()
+		return nil, nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+	drop := func() {
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to drop schema: %s\n", err)
+		}
+		admin.Close()
+	}
+
+	db, err := sql.Open("postgres", cfg.DatabaseUrl()+"&search_path="+schema+",public")
+	if err != nil {
+		drop()
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	files, err := filepath.Glob("../../migrations/*.up.sql")
+	if err != nil {
+		drop()
+		return nil, nil, err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err == nil {
+			_, err = db.Exec(string(migration))
+		}
+		if err != nil {
+			db.Close()
+			drop()
+			return nil, nil, fmt.Errorf("failed to apply %s: %w", filepath.Base(file), err)
+		}
+	}
+	return db, func() {
+		db.Close()
+		drop()
+	}, nil
+}
+
+// newTestStore returns a store on the test database, skipping the test when there is none.
+func newTestStore(tb testing.TB) *Store {
+	tb.Helper()
+	if testDb == nil {
+		tb.Skip("no test database: run with ENV=dev and DB_TEST_PORT set")
+	}
+	return NewStore(testDb)
+}
+
+var userSeq atomic.Int64
+
+// createUser inserts a user with a unique username starting with name.
+func createUser(tb testing.TB, s *Store, name string) *User {
+	tb.Helper()
+	username := fmt.Sprintf("%s%d", name, userSeq.Add(1))
+	user, err := s.User.CreateUser(context.Background(), username, username+"@example.com", "password")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return user
+}
+
+// createPost publishes a post by author with the fields of post that are set, defaulting the rest.
+func createPost(tb testing.TB, s *Store, author *User, post NewPost) *Posts {
+	tb.Helper()
+	if post.Title == "" {
+		post.Title = "title"
+	}
+	if post.Content == "" {
+		post.Content = "content"
+	}
+	if post.Language == "" {
+		post.Language = "english"
+	}
+	if post.Format == "" {
+		post.Format = FormatPlain
+	}
+	if post.Status == "" {
+		post.Status = PostPublished
+	}
+	if post.Visibility == "" {
+		post.Visibility = VisibilityPublic
+	}
+	ctx := context.WithValue(context.Background(), "user", author)
+	created, err := s.Posts.CreatePost(ctx, post)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return created
+}
+
+func follow(tb testing.TB, s *Store, follower, followee *User) {
+	tb.Helper()
+	if _, err := s.Follows.Follow(context.Background(), follower.Id, followee.Id); err != nil {
+		tb.Fatal(err)
+	}
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+type TagStore struct {
+	db *sqlx.DB
+}
+
+func NewTagStore(db *sql.DB) *TagStore {
+	return &TagStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+type TrendingTag struct {
+	Name       string    `db:"name" json:"name"`
+	PostCount  int       `db:"post_count" json:"post_count"`
+	Score      float64   `db:"score" json:"score"`
+	ComputedAt time.Time `db:"computed_at" json:"computed_at"`
+}
+
+// SetPostTags replaces the tags of postId with tags, creating tags seen for the first time.
+func (s *TagStore) SetPostTags(ctx context.Context, postId int, tags []string) error {
+	if tags == nil {
+		// A nil slice would be sent as NULL, which matches no existing tag for deletion.
+		tags = []string{}
+	}
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING`,
+		pq.Array(tags)); err != nil {
+		return fmt.Errorf("failed to insert tags: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM post_tags pt USING tags t
+		WHERE pt.tag_id = t.id AND pt.post_id = $1 AND NOT t.name = ANY($2)`, postId, pq.Array(tags)); err != nil {
+		return fmt.Errorf("failed to delete post tags: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO post_tags (tag_id, post_id, created_at)
+		SELECT t.id, p.id, p.created_at FROM tags t, posts p WHERE t.name = ANY($2) AND p.id = $1
+		ON CONFLICT DO NOTHING`, postId, pq.Array(tags)); err != nil {
+		return fmt.Errorf("failed to insert post tags: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit post tags: %w", err)
+	}
+	return nil
+}
+
+// ListPostsByTag returns a page of the posts tagged with tag that viewerId may see, newest first.
+func (s *TagStore) ListPostsByTag(ctx context.Context, tag string, viewerId int, page Page) ([]Posts, *Cursor, error) {
+	keyset, args := page.where("pt.created_at", "pt.post_id", []any{tag, viewerId})
+	order, args := page.orderLimit("pt.created_at", "pt.post_id", args)
+	query := `SELECT ` + postColumns + ` ` + postFrom + `
+		JOIN post_tags pt ON pt.post_id = p.id
+		JOIN tags t ON t.id = pt.tag_id
+		WHERE t.name = $1 AND ` + listedPost("p", "$2") + ` AND ` + keyset + ` ` + order
+	var posts []Posts
+	if err := s.db.SelectContext(ctx, &posts, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("failed to list posts by tag: %w", err)
+	}
+	posts, next := paginate(posts, page.Limit, Posts.cursor)
+	return posts, next, nil
+}
+
+// RecomputeTrending replaces the trending tags of the named window with the limit tags whose use
+// over the last window most outpaces their use over the window before it. A tag's score is
+// its post count in the window multiplied by its growth over the previous window, so tags
+// that are both busy and accelerating come first. Only posts visible to everyone are counted.
+// If another process is recomputing the same window, it leaves the window to that process.
+func (s *TagStore) RecomputeTrending(ctx context.Context, name string, window time.Duration, limit int) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if locked, err := tryJobLock(ctx, tx, lockTrending, name); err != nil || !locked {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM trending_tags WHERE time_window = $1`, name); err != nil {
+		return fmt.Errorf("failed to delete trending tags: %w", err)
+	}
+	dml := `WITH counts AS (
+			SELECT tag_id,
+				COUNT(*) FILTER (WHERE pt.created_at > now() - make_interval(secs => $2)) AS recent,
+				COUNT(*) FILTER (WHERE pt.created_at <= now() - make_interval(secs => $2)) AS previous
+			FROM post_tags pt JOIN posts p ON p.id = pt.post_id
+			WHERE pt.created_at > now() - 2 * make_interval(secs => $2) AND pt.created_at <= now()
+				AND ` + publishedPost("p") + ` AND ` + publicPost("p") + `
+			GROUP BY tag_id
+		)
+		INSERT INTO trending_tags (time_window, tag_id, post_count, score)
+		SELECT $1, tag_id, recent, recent * recent::float8 / (previous + 1)
+		FROM counts
+		WHERE recent > 0
+		ORDER BY 4 DESC
+		LIMIT $3`
+	if _, err := tx.ExecContext(ctx, dml, name, window.Seconds(), limit); err != nil {
+		return fmt.Errorf("failed to insert trending tags: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit trending tags: %w", err)
+	}
+	return nil
+}
+
+// ListTrending returns the trending tags last computed for the named window, highest score first.
+func (s *TagStore) ListTrending(ctx context.Context, name string) ([]TrendingTag, error) {
+	query := `SELECT t.name, tt.post_count, tt.score, tt.computed_at
+		FROM trending_tags tt JOIN tags t ON t.id = tt.tag_id
+		WHERE tt.time_window = $1
+		ORDER BY tt.score DESC, t.name`
+	var tags []TrendingTag
+	if err := s.db.SelectContext(ctx, &tags, query, name); err != nil {
+		return nil, fmt.Errorf("failed to list trending tags: %w", err)
+	}
+	if tags == nil {
+		tags = []TrendingTag{}
+	}
+	return tags, nil
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecomputeTrendingConcurrently(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	tag := author.Username
+	for i := 0; i < 3; i++ {
+		post := createPost(t, s, author, NewPost{})
+		if err := s.Tags.SetPostTags(ctx, post.Id, []string{tag}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.Tags.RecomputeTrending(ctx, "test", time.Hour, 50)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent recompute failed: %v", err)
+		}
+	}
+
+	trending, err := s.Tags.ListTrending(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := 0
+	for _, tt := range trending {
+		if tt.Name == tag {
+			found++
+		}
+	}
+	if found != 1 {
+		t.Fatalf("tag listed %d times, want once: %+v", found, trending)
+	}
+}
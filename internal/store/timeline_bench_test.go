@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"flag"
+	"testing"
+)
+
+var (
+	benchFollowees = flag.Int("bench.followees", 5000, "number of accounts the benchmark viewer follows")
+	benchPosts     = flag.Int("bench.posts", 20, "number of posts per followed account")
+	benchPages     = flag.Int("bench.pages", 5, "number of consecutive timeline pages read per iteration")
+	benchPageSize  = flag.Int("bench.page-size", 20, "posts per timeline page")
+)
+
+// benchViewers are the viewers seeded by the first run of the benchmark, which the runs with
+// larger b.N reuse.
+var benchViewers struct {
+	materialized, pulled int
+}
+
+// seedTimeline inserts a viewer following *benchFollowees accounts with *benchPosts posts each,
+// spread over the last 90 days, and returns the viewer's id. If pulled is set, the accounts are
+// marked as skipped by fan-out, so that the timeline is read from their posts. Otherwise the
+// posts are put in the viewer's materialized timeline, as fan-out would.
+func seedTimeline(b *testing.B, s *Store, pulled bool) int {
+	b.Helper()
+	viewer := createUser(b, s, "bench_viewer")
+	prefix := viewer.Username + "_"
+	if _, err := testDb.Exec(`INSERT INTO users (username, email, hashed_password, fanout_skipped)
+		SELECT $1 || g, $1 || g || '@bench.invalid', '', $3 FROM generate_series(1, $2) g`,
+		prefix, *benchFollowees, pulled); err != nil {
+		b.Fatalf("failed to insert followees: %s", err)
+	}
+	if _, err := testDb.Exec(`INSERT INTO follows (follower_id, followee_id)
+		SELECT $1, id FROM users WHERE starts_with(username, $2)`, viewer.Id, prefix); err != nil {
+		b.Fatalf("failed to insert follows: %s", err)
+	}
+	if _, err := testDb.Exec(`INSERT INTO posts (user_id, title, content, created_at)
+		SELECT u.id, 'bench post', 'bench content', CURRENT_TIMESTAMP - random() * INTERVAL '90 days'
+		FROM users u CROSS JOIN generate_series(1, $2)
+		WHERE starts_with(u.username, $1)`, prefix, *benchPosts); err != nil {
+		b.Fatalf("failed to insert posts: %s", err)
+	}
+	if !pulled {
+		if _, err := testDb.Exec(`INSERT INTO home_timelines (user_id, post_id, author_id, created_at)
+			SELECT $1, p.id, p.user_id, p.created_at FROM posts p JOIN users u ON u.id = p.user_id
+			WHERE starts_with(u.username, $2)`, viewer.Id, prefix); err != nil {
+			b.Fatalf("failed to insert timeline entries: %s", err)
+		}
+	}
+	if _, err := testDb.Exec(`ANALYZE users, follows, posts, home_timelines`); err != nil {
+		b.Fatalf("failed to analyze tables: %s", err)
+	}
+	return viewer.Id
+}
+
+// BenchmarkListHomeTimeline reads *benchPages consecutive pages of the home timeline of a viewer
+// following many accounts, reporting the time per page, both for a timeline materialized by
+// fan-out and for one pulled from the posts of accounts fan-out skipped. Run it with
+//
+//	ENV=dev go test ./internal/store -run '^$' -bench ListHomeTimeline
+func BenchmarkListHomeTimeline(b *testing.B) {
+	s := newTestStore(b)
+	if benchViewers.materialized == 0 {
+		benchViewers.materialized = seedTimeline(b, s, false)
+		benchViewers.pulled = seedTimeline(b, s, true)
+	}
+	b.Run("materialized", func(b *testing.B) {
+		benchmarkHomeTimeline(b, s, benchViewers.materialized)
+	})
+	b.Run("pulled", func(b *testing.B) {
+		benchmarkHomeTimeline(b, s, benchViewers.pulled)
+	})
+}
+
+func benchmarkHomeTimeline(b *testing.B, s *Store, viewerId int) {
+	ctx := context.Background()
+	// No account reaches the follower limit; the pulled viewer's accounts are marked instead.
+	maxFollowers := *benchFollowees + 1
+	pages := 0
+	for i := 0; i < b.N; i++ {
+		page := Page{Limit: *benchPageSize}
+		for p := 0; p < *benchPages; p++ {
+			_, next, err := s.Timelines.ListHomeTimeline(ctx, viewerId, maxFollowers, page)
+			if err != nil {
+				b.Fatal(err)
+			}
+			pages++
+			if next == nil {
+				break
+			}
+			page.Cursor = next
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(pages), "ns/page")
+}
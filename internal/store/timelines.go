@@ -0,0 +1,191 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"strconv"
+)
+
+// TimelineStore maintains materialized home timelines. Posts and reposts by accounts with more
+// than maxFollowers followers are not fanned out; ListHomeTimeline pulls them in at read time
+// instead. Skipping fan-out marks the account, and the posts and reposts of marked accounts keep
+// being pulled in after the account drops back under the limit, so that the ones it made above
+// the limit stay in its followers' timelines.
+type TimelineStore struct {
+	db *sqlx.DB
+}
+
+func NewTimelineStore(db *sql.DB) *TimelineStore {
+	return &TimelineStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// FanOut inserts postId into the timelines of its author and of the author's followers its
+// visibility lets see it, unless the author has more than maxFollowers followers, in which case
+// the author is marked as skipped. It reports whether the post was fanned out.
+func (s *TimelineStore) FanOut(ctx context.Context, postId, maxFollowers int) (bool, error) {
+	dml := `WITH skipped AS (
+			UPDATE users a SET fanout_skipped = TRUE FROM posts p
+			WHERE p.id = $1 AND a.id = p.user_id AND a.follower_count > $2 AND NOT a.fanout_skipped
+		), post AS (
+			SELECT p.id, p.user_id, p.created_at, p.visibility FROM posts p JOIN users a ON a.id = p.user_id
+			WHERE p.id = $1 AND ` + publishedPost("p") + ` AND a.follower_count <= $2
+		)
+		INSERT INTO home_timelines (user_id, post_id, author_id, created_at)
+		SELECT f.follower_id, post.id, post.user_id, post.created_at FROM post JOIN follows f ON f.followee_id = post.user_id
+		WHERE ` + visibleTo("post", "f.follower_id") + `
+		UNION ALL
+		SELECT post.user_id, post.id, post.user_id, post.created_at FROM post
+		ON CONFLICT DO NOTHING`
+	result, err := s.db.ExecContext(ctx, dml, postId, maxFollowers)
+	if err != nil {
+		return false, fmt.Errorf("failed to fan out post: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to fan out post: %w", err)
+	}
+	return n > 0, nil
+}
+
+// FanOutRepost inserts the post reposted by repostId into the timelines of the reposter and
+// their followers, unless the reposter has more than maxFollowers followers, in which case the
+// reposter is marked as skipped, or the post isn't visible to everyone. Timelines already holding
+// the post keep the entry they have. It reports whether the repost was fanned out.
+func (s *TimelineStore) FanOutRepost(ctx context.Context, repostId, maxFollowers int) (bool, error) {
+	dml := `WITH skipped AS (
+			UPDATE users a SET fanout_skipped = TRUE FROM reposts r
+			WHERE r.id = $1 AND a.id = r.user_id AND a.follower_count > $2 AND NOT a.fanout_skipped
+		), repost AS (
+			SELECT r.id, r.user_id, r.post_id, r.created_at FROM reposts r
+			JOIN posts p ON p.id = r.post_id
+			JOIN users a ON a.id = r.user_id
+			WHERE r.id = $1 AND ` + publishedPost("p") + ` AND ` + publicPost("p") + ` AND a.follower_count <= $2
+		)
+		INSERT INTO home_timelines (user_id, post_id, author_id, created_at, repost_id)
+		SELECT f.follower_id, repost.post_id, repost.user_id, repost.created_at, repost.id FROM repost JOIN follows f ON f.followee_id = repost.user_id
+		UNION ALL
+		SELECT repost.user_id, repost.post_id, repost.user_id, repost.created_at, repost.id FROM repost
+		ON CONFLICT DO NOTHING`
+	result, err := s.db.ExecContext(ctx, dml, repostId, maxFollowers)
+	if err != nil {
+		return false, fmt.Errorf("failed to fan out repost: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to fan out repost: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Backfill copies the latest limit posts and reposts of authorId into the timeline of userId,
+// for when userId starts following them. It does nothing if userId no longer follows authorId.
+func (s *TimelineStore) Backfill(ctx context.Context, userId, authorId, limit int) error {
+	dml := `INSERT INTO home_timelines (user_id, post_id, author_id, created_at, repost_id)
+		SELECT $1, e.post_id, $2, e.created_at, e.repost_id FROM (
+			SELECT p.id AS post_id, p.created_at, NULL::BIGINT AS repost_id FROM posts p
+			WHERE p.user_id = $2 AND ` + listedPost("p", "$1") + `
+			UNION ALL
+			SELECT r.post_id, r.created_at, r.id FROM reposts r JOIN posts p ON p.id = r.post_id
+			WHERE r.user_id = $2 AND ` + listedPost("p", "$1") + `
+		) e
+		WHERE EXISTS (SELECT 1 FROM follows f WHERE f.follower_id = $1 AND f.followee_id = $2)
+		ORDER BY e.created_at DESC, e.post_id DESC
+		LIMIT $3
+		ON CONFLICT DO NOTHING`
+	if _, err := s.db.ExecContext(ctx, dml, userId, authorId, limit); err != nil {
+		return fmt.Errorf("failed to backfill timeline: %w", err)
+	}
+	return nil
+}
+
+// RemoveAuthor deletes the posts and reposts of authorId from the timeline of userId, for when
+// userId stops following them.
+func (s *TimelineStore) RemoveAuthor(ctx context.Context, userId, authorId int) error {
+	dml := `DELETE FROM home_timelines WHERE user_id = $1 AND author_id = $2`
+	if _, err := s.db.ExecContext(ctx, dml, userId, authorId); err != nil {
+		return fmt.Errorf("failed to remove author from timeline: %w", err)
+	}
+	return nil
+}
+
+// RemoveHiddenReposts deletes the reposts of the posts of authorId by other users from the
+// timelines of users who may no longer see those posts, for when authorId makes their account
+// private.
+func (s *TimelineStore) RemoveHiddenReposts(ctx context.Context, authorId int) error {
+	dml := `DELETE FROM home_timelines ht USING posts p
+		WHERE p.id = ht.post_id AND p.user_id = $1 AND ht.repost_id IS NOT NULL
+			AND NOT ` + visibleTo("p", "ht.user_id")
+	if _, err := s.db.ExecContext(ctx, dml, authorId); err != nil {
+		return fmt.Errorf("failed to remove hidden reposts from timelines: %w", err)
+	}
+	return nil
+}
+
+// ListHomeTimeline returns a page of the materialized home timeline of userId merged with the
+// recent posts and reposts of the accounts over maxFollowers, or that ever were, which weren't
+// all fanned out. Entries are ordered by when they entered the timeline, and a post that entered
+// it more than once is only listed by its latest entry that userId didn't hide by blocking or
+// muting the reposter, even when that entry is on an earlier page. Each source is filtered
+// before it is limited, so that entries userId can't see don't crowd out, and cut the page short
+// of, those they can.
+func (s *TimelineStore) ListHomeTimeline(ctx context.Context, userId, maxFollowers int, page Page) ([]Posts, *Cursor, error) {
+	materialized, args := page.where("ht.created_at", "ht.post_id", []any{userId, maxFollowers})
+	pulled, args := page.where("tp.created_at", "tp.id", args)
+	reposted, args := page.where("tr.created_at", "tr.post_id", args)
+	order, args := page.orderLimit("e.created_at", "e.post_id", args)
+	limit := "$" + strconv.Itoa(len(args))
+	query := `WITH authors AS (
+			SELECT u.id AS user_id FROM follows f JOIN users u ON u.id = f.followee_id
+			WHERE f.follower_id = $1 AND (u.follower_count > $2 OR u.fanout_skipped)
+			UNION ALL
+			SELECT u.id FROM users u WHERE u.id = $1 AND (u.follower_count > $2 OR u.fanout_skipped)
+		), candidates AS (
+			(
+				SELECT ht.post_id, ht.created_at, ht.repost_id FROM home_timelines ht
+				JOIN posts hp ON hp.id = ht.post_id
+				WHERE ht.user_id = $1 AND ` + materialized + ` AND ` + listedPost("hp", "$1") + `
+					AND ` + shownRepost("ht.repost_id", "$1") + `
+				ORDER BY ht.created_at DESC, ht.post_id DESC
+				LIMIT ` + limit + `
+			)
+			UNION ALL
+			SELECT recent.* FROM authors CROSS JOIN LATERAL (
+				SELECT tp.id AS post_id, tp.created_at, NULL::BIGINT AS repost_id FROM posts tp
+				WHERE tp.user_id = authors.user_id AND ` + listedPost("tp", "$1") + ` AND ` + pulled + `
+				ORDER BY tp.created_at DESC, tp.id DESC
+				LIMIT ` + limit + `
+			) recent
+			UNION ALL
+			SELECT recent.* FROM authors CROSS JOIN LATERAL (
+				SELECT tr.post_id, tr.created_at, tr.id AS repost_id FROM reposts tr
+				JOIN posts rp ON rp.id = tr.post_id
+				WHERE tr.user_id = authors.user_id AND ` + reposted + ` AND ` + listedPost("rp", "$1") + `
+					AND ` + shownRepost("tr.id", "$1") + `
+				ORDER BY tr.created_at DESC, tr.post_id DESC
+				LIMIT ` + limit + `
+			) recent
+		), entries AS (
+			SELECT DISTINCT ON (c.post_id) c.post_id, c.created_at, c.repost_id FROM candidates c
+			WHERE NOT EXISTS (
+					SELECT 1 FROM home_timelines lh
+					WHERE lh.user_id = $1 AND lh.post_id = c.post_id AND lh.created_at > c.created_at
+						AND ` + shownRepost("lh.repost_id", "$1") + `
+				) AND NOT EXISTS (
+					SELECT 1 FROM reposts lr JOIN authors la ON la.user_id = lr.user_id
+					WHERE lr.post_id = c.post_id AND lr.created_at > c.created_at
+						AND ` + shownRepost("lr.id", "$1") + `
+				)
+			ORDER BY c.post_id, c.created_at DESC
+		)
+		` + entrySelect + ` ` + order
+	posts, next, err := selectEntries(ctx, s.db, query, args, page.Limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list home timeline: %w", err)
+	}
+	return posts, next, nil
+}
@@ -0,0 +1,302 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// homeTimeline returns the ids of the posts on the first page of the home timeline of user.
+func homeTimeline(t *testing.T, s *Store, user *User, maxFollowers int) []int {
+	t.Helper()
+	posts, _, err := s.Timelines.ListHomeTimeline(context.Background(), user.Id, maxFollowers, Page{Limit: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int, len(posts))
+	for i, p := range posts {
+		ids[i] = p.Id
+	}
+	return ids
+}
+
+// pagedHomeTimeline returns the ids of the posts in the home timeline of user, read limit at a
+// time.
+func pagedHomeTimeline(t *testing.T, s *Store, user *User, maxFollowers, limit int) []int {
+	t.Helper()
+	var ids []int
+	page := Page{Limit: limit}
+	for {
+		posts, next, err := s.Timelines.ListHomeTimeline(context.Background(), user.Id, maxFollowers, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, postIds(posts)...)
+		if next == nil {
+			return ids
+		}
+		page.Cursor = next
+	}
+}
+
+// backdate moves the creation of post the given time into the past.
+func backdate(t *testing.T, post *Posts, d time.Duration) {
+	t.Helper()
+	if _, err := testDb.Exec(`UPDATE posts SET created_at = created_at - $2 * INTERVAL '1 microsecond' WHERE id = $1`,
+		post.Id, d.Microseconds()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func containsId(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHomeTimelineKeepsPostsSkippedAboveFollowerLimit(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	reader := createUser(t, s, "reader")
+	follow(t, s, reader, author)
+	follow(t, s, createUser(t, s, "fan"), author)
+
+	// With two followers the author is over a limit of one, so the post isn't fanned out.
+	post := createPost(t, s, author, NewPost{})
+	fannedOut, err := s.Timelines.FanOut(ctx, post.Id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fannedOut {
+		t.Fatal("post of an author over the limit was fanned out")
+	}
+	if !containsId(homeTimeline(t, s, reader, 1), post.Id) {
+		t.Fatal("post pulled in over the limit is missing")
+	}
+	// Raising the limit is what the author dropping under it looks like to the timeline.
+	if !containsId(homeTimeline(t, s, reader, 10), post.Id) {
+		t.Fatal("post made over the limit disappeared once the author was under it")
+	}
+}
+
+func TestDeliveriesRunOnceAndRetryWhenEnqueuedAgain(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	post := createPost(t, s, author, NewPost{})
+
+	delivery, err := s.Deliveries.ClaimPost(ctx, post.Id, time.Minute)
+	if err != nil {
+		t.Fatalf("creating a published post didn't queue its delivery: %v", err)
+	}
+	if _, err := s.Deliveries.ClaimPost(ctx, post.Id, time.Minute); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("second claim error = %v, want sql.ErrNoRows", err)
+	}
+
+	// A change of visibility while the delivery runs asks for it to run again.
+	if _, err := s.Posts.UpdatePost(ctx, post.Id, NewPost{
+		Title: post.Title, Content: post.Content, Language: post.Language, Format: post.Format,
+		Status: PostPublished, Visibility: VisibilityFollowers,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Deliveries.Complete(ctx, delivery); err != nil {
+		t.Fatal(err)
+	}
+	again, err := s.Deliveries.ClaimPost(ctx, post.Id, time.Minute)
+	if err != nil {
+		t.Fatalf("delivery enqueued again was completed by the earlier run: %v", err)
+	}
+	if err := s.Deliveries.Complete(ctx, again); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Deliveries.ClaimPost(ctx, post.Id, time.Minute); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("claim after completion error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestStaleDeliveriesAreListedForRetry(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	post := createPost(t, s, author, NewPost{})
+	draft := createPost(t, s, author, NewPost{Status: PostDraft})
+
+	if _, err := testDb.Exec(`UPDATE deliveries SET created_at = created_at - INTERVAL '1 hour'
+		WHERE post_id = $1`, post.Id); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := s.Deliveries.ListDue(ctx, time.Minute, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := make(map[int]bool)
+	for _, d := range deliveries {
+		if d.PostId != nil {
+			listed[*d.PostId] = true
+		}
+	}
+	if !listed[post.Id] {
+		t.Fatal("stale delivery wasn't listed")
+	}
+	if listed[draft.Id] {
+		t.Fatal("draft was queued for delivery")
+	}
+}
+
+func TestPublishDueQueuesDelivery(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	publishAt := time.Now().Add(time.Hour)
+	post := createPost(t, s, author, NewPost{Status: PostScheduled, PublishAt: &publishAt})
+	if _, err := testDb.Exec(`UPDATE posts SET publish_at = CURRENT_TIMESTAMP - INTERVAL '1 minute'
+		WHERE id = $1`, post.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := s.Posts.PublishDue(ctx, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsId(ids, post.Id) {
+		t.Fatalf("PublishDue returned %v, want it to include %d", ids, post.Id)
+	}
+	if _, err := s.Deliveries.ClaimPost(ctx, post.Id, time.Minute); err != nil {
+		t.Fatalf("published post wasn't queued for delivery: %v", err)
+	}
+}
+
+func TestHomeTimelinePagesPastHiddenEntries(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	reader := createUser(t, s, "reader")
+	older := createUser(t, s, "older")
+	newer := createUser(t, s, "newer")
+	follow(t, s, reader, older)
+	follow(t, s, reader, newer)
+
+	var want []int
+	for i := 0; i < 3; i++ {
+		post := createPost(t, s, older, NewPost{})
+		if _, err := s.Timelines.FanOut(ctx, post.Id, 10); err != nil {
+			t.Fatal(err)
+		}
+		want = append([]int{post.Id}, want...)
+	}
+	// More deleted entries on top than fit on a page, which used to end the timeline there.
+	for i := 0; i < 5; i++ {
+		post := createPost(t, s, newer, NewPost{})
+		if _, err := s.Timelines.FanOut(ctx, post.Id, 10); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Posts.DeletePost(ctx, post.Id, newer.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := pagedHomeTimeline(t, s, reader, 10, 2); !slices.Equal(got, want) {
+		t.Fatalf("timeline = %v, want %v", got, want)
+	}
+}
+
+func TestUserFeedListsSelfRepostOnce(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	viewer := createUser(t, s, "viewer")
+	post := createPost(t, s, author, NewPost{})
+	if _, err := s.Reposts.Repost(ctx, author.Id, post.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	posts, _, err := s.Posts.ListPostsByUser(ctx, author.Id, viewer.Id, Page{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || posts[0].Id != post.Id || posts[0].RepostedBy == nil {
+		t.Fatalf("feed = %+v, want the post once, as a repost", posts)
+	}
+}
+
+func TestHomeTimelineListsRepostedPostOnceAcrossPages(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	reader := createUser(t, s, "reader")
+	author := createUser(t, s, "author")
+	reposter := createUser(t, s, "reposter")
+	follow(t, s, reader, author)
+	follow(t, s, reader, reposter)
+	// The author's posts are pulled in, and the reposter's reposts are fanned out.
+	if _, err := testDb.Exec(`UPDATE users SET fanout_skipped = TRUE WHERE id = $1`, author.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	post := createPost(t, s, author, NewPost{})
+	backdate(t, post, 2*time.Hour)
+	middle := createPost(t, s, reposter, NewPost{})
+	backdate(t, middle, time.Hour)
+	if _, err := s.Timelines.FanOut(ctx, middle.Id, 10); err != nil {
+		t.Fatal(err)
+	}
+	repostId, err := s.Reposts.Repost(ctx, reposter.Id, post.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Timelines.FanOutRepost(ctx, repostId, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	// The repost is on the first page and the post itself would be on the second.
+	want := []int{post.Id, middle.Id}
+	if got := pagedHomeTimeline(t, s, reader, 10, 1); !slices.Equal(got, want) {
+		t.Fatalf("timeline = %v, want %v", got, want)
+	}
+}
+
+func TestUndoRepostKeepsPostOfFollowedAuthor(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	reader := createUser(t, s, "reader")
+	author := createUser(t, s, "author")
+	reposter := createUser(t, s, "reposter")
+	follow(t, s, reader, reposter)
+
+	post := createPost(t, s, author, NewPost{})
+	backdate(t, post, 2*time.Hour)
+	middle := createPost(t, s, reposter, NewPost{})
+	backdate(t, middle, time.Hour)
+	if _, err := s.Timelines.FanOut(ctx, middle.Id, 10); err != nil {
+		t.Fatal(err)
+	}
+	repostId, err := s.Reposts.Repost(ctx, reposter.Id, post.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Timelines.FanOutRepost(ctx, repostId, 10); err != nil {
+		t.Fatal(err)
+	}
+	// Following the author afterwards leaves the repost as the timeline's entry for the post.
+	follow(t, s, reader, author)
+	if err := s.Timelines.Backfill(ctx, reader.Id, author.Id, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Reposts.Undo(ctx, reposter.Id, post.Id); err != nil {
+		t.Fatal(err)
+	}
+	want := []int{middle.Id, post.Id}
+	if got := pagedHomeTimeline(t, s, reader, 10, 1); !slices.Equal(got, want) {
+		t.Fatalf("timeline = %v, want %v", got, want)
+	}
+	if inTimeline(t, reposter, post.Id) {
+		t.Fatal("undone repost left in the timeline of the reposter, who doesn't follow the author")
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 	"time"
 )
@@ -22,11 +22,23 @@ func NewUsersStore(db *sql.DB) *UsersStore {
 }
 
 type User struct {
-	Id                   int       `db:"id"`
-	Email                string    `db:"email"`
-	Username             string    `db:"username"`
-	HashedPasswordBase64 string    `db:"hashed_password"`
-	CreatedAt            time.Time `db:"created_at"`
+	Id                   int       `db:"id" json:"-"`
+	PublicId             string    `db:"public_id" json:"id"`
+	Email                string    `db:"email" json:"-"`
+	Username             string    `db:"username" json:"username"`
+	DisplayName          string    `db:"display_name" json:"display_name"`
+	HashedPasswordBase64 string    `db:"hashed_password" json:"-"`
+	Role                 string    `db:"role" json:"role"`
+	Private              bool      `db:"is_private" json:"private"`
+	FollowerCount        int       `db:"follower_count" json:"follower_count"`
+	FollowingCount       int       `db:"following_count" json:"following_count"`
+	FanoutSkipped        bool      `db:"fanout_skipped" json:"-"`
+	CreatedAt            time.Time `db:"created_at" json:"created_at"`
+}
+
+// IsModerator reports whether the user may moderate content written by others.
+func (u *User) IsModerator() bool {
+	return u.Role == "moderator" || u.Role == "admin"
 }
 
 func (u *User) CheckHashedPassword(password string) error {
@@ -76,6 +88,16 @@ func (s *UsersStore) GetUserByUsername(ctx context.Context, username string) (*U
 	return &user, nil
 }
 
+// GetUsersByUsernames returns the users named by usernames, leaving out names that don't exist.
+func (s *UsersStore) GetUsersByUsernames(ctx context.Context, usernames []string) ([]User, error) {
+	query := `SELECT * FROM users WHERE username = ANY($1)`
+	var users []User
+	if err := s.db.SelectContext(ctx, &users, query, pq.Array(usernames)); err != nil {
+		return nil, fmt.Errorf("failed to query users by username: %w", err)
+	}
+	return users, nil
+}
+
 func (s *UsersStore) GetUserById(ctx context.Context, id int) (*User, error) {
 	query := `SELECT * FROM users WHERE id = $1`
 	var user User
@@ -84,3 +106,24 @@ func (s *UsersStore) GetUserById(ctx context.Context, id int) (*User, error) {
 	}
 	return &user, nil
 }
+
+func (s *UsersStore) GetUserByPublicId(ctx context.Context, publicId string) (*User, error) {
+	query := `SELECT * FROM users WHERE public_id = $1`
+	var user User
+	if err := s.db.GetContext(ctx, &user, query, publicId); err != nil {
+		return nil, fmt.Errorf("failed to query user by public id: %w", err)
+	}
+	return &user, nil
+}
+
+// UpdateProfile sets the display name of user id and whether the account is private, leaving
+// either unchanged when it is nil.
+func (s *UsersStore) UpdateProfile(ctx context.Context, id int, displayName *string, private *bool) (*User, error) {
+	dml := `UPDATE users SET display_name = COALESCE($2, display_name), is_private = COALESCE($3, is_private)
+		WHERE id = $1 RETURNING *`
+	var user User
+	if err := s.db.GetContext(ctx, &user, dml, id, displayName, private); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	return &user, nil
+}
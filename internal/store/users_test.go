@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetUsersByUsernames(t *testing.T) {
+	s := newTestStore(t)
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	users, err := s.User.GetUsersByUsernames(context.Background(),
+		[]string{alice.Username, bob.Username, "nobody" + alice.Username})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[int]bool)
+	for _, u := range users {
+		found[u.Id] = true
+	}
+	if len(users) != 2 || !found[alice.Id] || !found[bob.Id] {
+		t.Fatalf("GetUsersByUsernames returned %+v, want alice and bob", users)
+	}
+}
+
+func TestUpdateProfileKeepsFieldsLeftOut(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	user := createUser(t, s, "user")
+	name := "Gopher"
+	if _, err := s.User.UpdateProfile(ctx, user.Id, &name, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	private := true
+	updated, err := s.User.UpdateProfile(ctx, user.Id, nil, &private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.DisplayName != name || !updated.Private {
+		t.Fatalf("after a private-only update: display name %q, private %v", updated.DisplayName, updated.Private)
+	}
+	updated, err = s.User.UpdateProfile(ctx, user.Id, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.DisplayName != name || !updated.Private {
+		t.Fatalf("after an empty update: display name %q, private %v", updated.DisplayName, updated.Private)
+	}
+}
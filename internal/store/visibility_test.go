@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// TestVisibility reads a followers-only and a mentioned-only post through every way posts reach
+// users, as each of a user who doesn't follow the author, a follower, a user mentioned in the
+// mentioned-only post and the author.
+func TestVisibility(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	author := createUser(t, s, "author")
+	stranger := createUser(t, s, "stranger")
+	follower := createUser(t, s, "follower")
+	mentioned := createUser(t, s, "mentioned")
+	actor := createUser(t, s, "actor")
+	follow(t, s, follower, author)
+
+	tag := "secret" + author.Username
+	users := []*User{stranger, follower, mentioned, author}
+	newPost := func(visibility string) *Posts {
+		t.Helper()
+		// Written public and bookmarked by everyone, before narrowing who may see it.
+		post := createPost(t, s, author, NewPost{Content: "quokka"})
+		for _, user := range users {
+			if err := s.Bookmarks.Bookmark(ctx, user.Id, post.Id, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		post, err := s.Posts.UpdatePost(ctx, post.Id, NewPost{
+			Title: post.Title, Content: post.Content, Language: "english", Status: PostPublished,
+			Format: FormatPlain, Visibility: visibility,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Tags.SetPostTags(ctx, post.Id, []string{tag}); err != nil {
+			t.Fatal(err)
+		}
+		return post
+	}
+	followersOnly := newPost(VisibilityFollowers)
+	mentionedOnly := newPost(VisibilityMentioned)
+	if err := s.Mentions.SetMentions(ctx, PostMentions, mentionedOnly.Id,
+		[]ResolvedMention{{UserId: mentioned.Id, Start: 0, End: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, post := range []*Posts{followersOnly, mentionedOnly} {
+		if _, err := s.Timelines.FanOut(ctx, post.Id, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Notifications about the posts, and about comments on them, sent to everyone regardless.
+	for _, post := range []*Posts{followersOnly, mentionedOnly} {
+		comment, err := s.Comments.CreateComment(ctx, post.Id, actor.Id, nil, "hi")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, user := range users {
+			for _, n := range []NewNotification{
+				{UserId: user.Id, ActorId: actor.Id, Kind: NotificationQuote, PostId: &post.Id},
+				{UserId: user.Id, ActorId: actor.Id, Kind: NotificationMention, PostId: &post.Id, CommentId: &comment.Id},
+			} {
+				if err := s.Notifications.Create(ctx, n); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+
+	page := Page{Limit: 100}
+	surfaces := []struct {
+		name string
+		// timeline is set for surfaces only listing the posts of followed accounts.
+		timeline bool
+		list     func(viewer *User) []int
+	}{
+		{name: "get", list: func(viewer *User) []int {
+			var ids []int
+			for _, post := range []*Posts{followersOnly, mentionedOnly} {
+				// The comment routes load the post this way too, before reading its comments.
+				if got, err := s.Posts.GetPostByPublicId(ctx, post.PublicId, viewer.Id); err == nil {
+					ids = append(ids, got.Id)
+				}
+			}
+			return ids
+		}},
+		{name: "feed", list: func(viewer *User) []int {
+			posts, _, err := s.Posts.ListPosts(ctx, viewer.Id, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return postIds(posts)
+		}},
+		{name: "bookmarks", list: func(viewer *User) []int {
+			posts, _, err := s.Bookmarks.List(ctx, viewer.Id, nil, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return postIds(posts)
+		}},
+		{name: "user feed", list: func(viewer *User) []int {
+			posts, _, err := s.Posts.ListPostsByUser(ctx, author.Id, viewer.Id, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return postIds(posts)
+		}},
+		{name: "search", list: func(viewer *User) []int {
+			results, _, err := s.Search.SearchPosts(ctx, "quokka", "english", viewer.Id, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]int, len(results))
+			for i, r := range results {
+				ids[i] = r.Id
+			}
+			return ids
+		}},
+		{name: "tag", list: func(viewer *User) []int {
+			posts, _, err := s.Tags.ListPostsByTag(ctx, tag, viewer.Id, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return postIds(posts)
+		}},
+		{name: "quote embed", list: func(viewer *User) []int {
+			posts, err := s.Posts.GetListedPostsByIds(ctx, []int{followersOnly.Id, mentionedOnly.Id}, viewer.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return postIds(posts)
+		}},
+		{name: "notifications", list: func(viewer *User) []int {
+			notifications, _, err := s.Notifications.List(ctx, viewer.Id, false, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, n := range notifications {
+				if n.CommentId == nil {
+					ids = append(ids, *n.PostId)
+				}
+			}
+			return ids
+		}},
+		{name: "comment notifications", list: func(viewer *User) []int {
+			notifications, _, err := s.Notifications.List(ctx, viewer.Id, false, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, n := range notifications {
+				if n.CommentId != nil {
+					ids = append(ids, *n.PostId)
+				}
+			}
+			return ids
+		}},
+		{name: "home timeline", timeline: true, list: func(viewer *User) []int {
+			return homeTimeline(t, s, viewer, 10)
+		}},
+		{name: "home timeline pulled", timeline: true, list: func(viewer *User) []int {
+			// Without the fanned out entries, and with every account over the follower limit, the
+			// author's posts are read from posts directly.
+			if _, err := testDb.Exec(`DELETE FROM home_timelines WHERE author_id = $1`, author.Id); err != nil {
+				t.Fatal(err)
+			}
+			return homeTimeline(t, s, viewer, -1)
+		}},
+	}
+
+	roles := []struct {
+		name string
+		user *User
+		// follows is whether the user follows the author, or is the author.
+		follows bool
+		// sees are the posts the user may see.
+		sees []*Posts
+	}{
+		{name: "stranger", user: stranger},
+		{name: "follower", user: follower, follows: true, sees: []*Posts{followersOnly}},
+		{name: "mentioned", user: mentioned, sees: []*Posts{mentionedOnly}},
+		{name: "author", user: author, follows: true, sees: []*Posts{followersOnly, mentionedOnly}},
+	}
+
+	for _, surface := range surfaces {
+		for _, role := range roles {
+			ids := surface.list(role.user)
+			for _, post := range []*Posts{followersOnly, mentionedOnly} {
+				want := slices.Contains(role.sees, post) && (role.follows || !surface.timeline)
+				if got := containsId(ids, post.Id); got != want {
+					t.Errorf("%s: %s sees %s post = %v, want %v", surface.name, role.name, post.Visibility, got, want)
+				}
+			}
+		}
+	}
+}
+
+func postIds(posts []Posts) []int {
+	ids := make([]int, len(posts))
+	for i, p := range posts {
+		ids[i] = p.Id
+	}
+	return ids
+}
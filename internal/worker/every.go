@@ -0,0 +1,23 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Every runs job immediately and then every interval until ctx is cancelled, logging failures.
+func Every(ctx context.Context, logger *slog.Logger, name string, interval time.Duration, job Job) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := job(ctx); err != nil && ctx.Err() == nil {
+			logger.Error("scheduled job failed", "job", name, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
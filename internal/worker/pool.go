@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Job is a unit of background work. The context is cancelled when the pool shuts down.
+type Job func(ctx context.Context) error
+
+type namedJob struct {
+	name string
+	run  Job
+}
+
+// Pool runs submitted jobs on a fixed number of goroutines, so request handlers can hand off
+// work that doesn't need to finish before they respond.
+type Pool struct {
+	logger  *slog.Logger
+	workers int
+	jobs    chan namedJob
+}
+
+func NewPool(logger *slog.Logger, workers, queueSize int) *Pool {
+	return &Pool{
+		logger:  logger,
+		workers: workers,
+		jobs:    make(chan namedJob, queueSize),
+	}
+}
+
+// Submit queues job without blocking. It reports false, dropping the job, when the queue is full.
+func (p *Pool) Submit(name string, job Job) bool {
+	select {
+	case p.jobs <- namedJob{name: name, run: job}:
+		return true
+	default:
+		p.logger.Error("worker queue full, dropping job", "job", name)
+		return false
+	}
+}
+
+// Run processes jobs until ctx is cancelled. Jobs still queued at that point are dropped.
+func (p *Pool) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-p.jobs:
+					if err := job.run(ctx); err != nil {
+						p.logger.Error("background job failed", "job", job.name, "error", err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,5 @@
+ALTER TABLE comments ALTER COLUMN id DROP DEFAULT;
+DROP SEQUENCE IF EXISTS comment_num_seq;
+
+ALTER TABLE posts ALTER COLUMN id DROP DEFAULT;
+DROP SEQUENCE IF EXISTS post_num_seq;
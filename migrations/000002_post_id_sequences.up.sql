@@ -0,0 +1,7 @@
+CREATE SEQUENCE post_num_seq OWNED BY posts.id;
+SELECT setval('post_num_seq', COALESCE((SELECT MAX(id) FROM posts), 0) + 1, false);
+ALTER TABLE posts ALTER COLUMN id SET DEFAULT nextval('post_num_seq');
+
+CREATE SEQUENCE comment_num_seq OWNED BY comments.id;
+SELECT setval('comment_num_seq', COALESCE((SELECT MAX(id) FROM comments), 0) + 1, false);
+ALTER TABLE comments ALTER COLUMN id SET DEFAULT nextval('comment_num_seq');
@@ -0,0 +1,5 @@
+ALTER TABLE comments DROP COLUMN IF EXISTS public_id;
+ALTER TABLE posts DROP COLUMN IF EXISTS public_id;
+ALTER TABLE users DROP COLUMN IF EXISTS public_id;
+
+DROP FUNCTION IF EXISTS generate_ulid();
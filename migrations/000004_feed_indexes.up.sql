@@ -0,0 +1,2 @@
+CREATE INDEX posts_created_at_id_idx ON posts (created_at DESC, id DESC);
+CREATE INDEX posts_user_id_created_at_id_idx ON posts (user_id, created_at DESC, id DESC);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS comments_post_id_created_at_id_idx;
+
+DROP TRIGGER IF EXISTS update_comments ON comments;
+ALTER TABLE comments DROP COLUMN IF EXISTS updated_at;
+
+ALTER TABLE users DROP COLUMN IF EXISTS role;
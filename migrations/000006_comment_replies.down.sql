@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS comments_parent_id_created_at_id_idx;
+DROP INDEX IF EXISTS comments_top_level_post_id_created_at_id_idx;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS parent_id;
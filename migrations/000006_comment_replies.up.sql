@@ -0,0 +1,5 @@
+ALTER TABLE comments ADD COLUMN parent_id BIGINT REFERENCES comments(id) ON DELETE CASCADE;
+
+CREATE INDEX comments_top_level_post_id_created_at_id_idx ON comments (post_id, created_at DESC, id DESC)
+    WHERE parent_id IS NULL;
+CREATE INDEX comments_parent_id_created_at_id_idx ON comments (parent_id, created_at DESC, id DESC);
@@ -0,0 +1,19 @@
+CREATE TABLE post_reactions (
+    post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('like', 'heart', 'laugh', 'wow', 'sad', 'angry')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, user_id, kind)
+);
+
+CREATE INDEX post_reactions_post_id_created_at_user_id_idx ON post_reactions (post_id, created_at DESC, user_id DESC);
+
+CREATE TABLE comment_reactions (
+    comment_id BIGINT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('like', 'heart', 'laugh', 'wow', 'sad', 'angry')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (comment_id, user_id, kind)
+);
+
+CREATE INDEX comment_reactions_comment_id_created_at_user_id_idx ON comment_reactions (comment_id, created_at DESC, user_id DESC);
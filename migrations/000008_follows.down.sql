@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS follows;
+DROP FUNCTION IF EXISTS update_follow_counts();
+
+ALTER TABLE users DROP COLUMN IF EXISTS following_count;
+ALTER TABLE users DROP COLUMN IF EXISTS follower_count;
@@ -0,0 +1,17 @@
+-- home_timelines holds the materialized home timeline of each user. Rows are removed by the
+-- foreign keys when a post or user is deleted, and by the application on unfollow.
+CREATE TABLE home_timelines (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    author_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, post_id)
+);
+
+CREATE INDEX home_timelines_user_id_created_at_post_id_idx ON home_timelines (user_id, created_at DESC, post_id DESC);
+CREATE INDEX home_timelines_user_id_author_id_idx ON home_timelines (user_id, author_id);
+
+INSERT INTO home_timelines (user_id, post_id, author_id, created_at)
+SELECT f.follower_id, p.id, p.user_id, p.created_at FROM follows f JOIN posts p ON p.user_id = f.followee_id
+UNION ALL
+SELECT p.user_id, p.id, p.user_id, p.created_at FROM posts p WHERE p.user_id IS NOT NULL;
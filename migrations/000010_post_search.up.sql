@@ -0,0 +1,7 @@
+ALTER TABLE posts ADD COLUMN language REGCONFIG NOT NULL DEFAULT 'english';
+
+ALTER TABLE posts ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector(language, title), 'A') || setweight(to_tsvector(language, content), 'B')
+) STORED;
+
+CREATE INDEX posts_search_vector_idx ON posts USING GIN (search_vector);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS users_display_name_trgm_idx;
+DROP INDEX IF EXISTS users_username_trgm_idx;
+
+ALTER TABLE users DROP COLUMN IF EXISTS display_name;
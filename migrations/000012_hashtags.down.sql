@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS trending_tags;
+
+DROP TRIGGER IF EXISTS sync_post_tags_created_at ON posts;
+DROP FUNCTION IF EXISTS sync_post_tags_created_at();
+
+DROP TABLE IF EXISTS post_tags;
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,40 @@
+CREATE TABLE tags (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- post_tags.created_at copies posts.created_at so tag pages can be paged through an index.
+CREATE TABLE post_tags (
+    tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (tag_id, post_id)
+);
+
+CREATE INDEX post_tags_tag_id_created_at_post_id_idx ON post_tags (tag_id, created_at DESC, post_id DESC);
+CREATE INDEX post_tags_post_id_idx ON post_tags (post_id);
+CREATE INDEX post_tags_created_at_idx ON post_tags (created_at);
+
+CREATE OR REPLACE FUNCTION sync_post_tags_created_at()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE post_tags SET created_at = NEW.created_at WHERE post_id = NEW.id;
+RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER sync_post_tags_created_at
+    AFTER UPDATE OF created_at ON posts
+    FOR EACH ROW
+    WHEN (OLD.created_at IS DISTINCT FROM NEW.created_at)
+    EXECUTE FUNCTION sync_post_tags_created_at();
+
+CREATE TABLE trending_tags (
+    time_window VARCHAR(16) NOT NULL,
+    tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    post_count INTEGER NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    computed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (time_window, tag_id)
+);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS posts_unpublished_user_id_created_at_id_idx;
+DROP INDEX IF EXISTS posts_scheduled_publish_at_idx;
+
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_scheduled_publish_at_check;
+ALTER TABLE posts DROP COLUMN IF EXISTS publish_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS status;
@@ -0,0 +1,8 @@
+ALTER TABLE posts ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'scheduled', 'published'));
+ALTER TABLE posts ADD COLUMN publish_at TIMESTAMPTZ;
+ALTER TABLE posts ADD CONSTRAINT posts_scheduled_publish_at_check CHECK (status <> 'scheduled' OR publish_at IS NOT NULL);
+
+CREATE INDEX posts_scheduled_publish_at_idx ON posts (publish_at) WHERE status = 'scheduled';
+CREATE INDEX posts_unpublished_user_id_created_at_id_idx ON posts (user_id, created_at DESC, id DESC)
+    WHERE status <> 'published';
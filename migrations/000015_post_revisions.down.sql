@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS record_post_revision ON posts;
+DROP FUNCTION IF EXISTS record_post_revision();
+
+DROP TABLE IF EXISTS post_revisions;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS edited_at;
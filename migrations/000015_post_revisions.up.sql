@@ -0,0 +1,28 @@
+ALTER TABLE posts ADD COLUMN edited_at TIMESTAMPTZ;
+
+-- post_revisions holds the earlier versions of published posts; created_at is when each was replaced.
+CREATE TABLE post_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX post_revisions_post_id_created_at_id_idx ON post_revisions (post_id, created_at DESC, id DESC);
+
+CREATE OR REPLACE FUNCTION record_post_revision()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO post_revisions (post_id, title, content) VALUES (OLD.id, OLD.title, OLD.content);
+    NEW.edited_at = CURRENT_TIMESTAMP;
+RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+-- Drafts can be rewritten freely; only changes to published posts are kept as revisions.
+CREATE TRIGGER record_post_revision
+    BEFORE UPDATE OF title, content ON posts
+    FOR EACH ROW
+    WHEN (OLD.status = 'published' AND (OLD.title IS DISTINCT FROM NEW.title OR OLD.content IS DISTINCT FROM NEW.content))
+    EXECUTE FUNCTION record_post_revision();
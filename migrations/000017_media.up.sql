@@ -0,0 +1,16 @@
+-- Media is uploaded before the post it belongs to exists. Rows whose post_id is NULL are either
+-- waiting to be attached or were detached, and are purged together with their blobs once old enough.
+CREATE TABLE media (
+    id BIGSERIAL PRIMARY KEY,
+    public_id CHAR(26) NOT NULL UNIQUE DEFAULT generate_ulid(),
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id BIGINT REFERENCES posts(id) ON DELETE SET NULL,
+    position SMALLINT,
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX media_post_id_position_idx ON media (post_id, position) WHERE post_id IS NOT NULL;
+CREATE INDEX media_unattached_created_at_idx ON media (created_at) WHERE post_id IS NULL;
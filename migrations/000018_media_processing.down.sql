@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS media_thumbnails;
+
+DROP INDEX IF EXISTS media_unprocessed_created_at_idx;
+
+ALTER TABLE media DROP COLUMN IF EXISTS blurhash;
+ALTER TABLE media DROP COLUMN IF EXISTS height;
+ALTER TABLE media DROP COLUMN IF EXISTS width;
+ALTER TABLE media DROP COLUMN IF EXISTS claimed_at;
+ALTER TABLE media DROP COLUMN IF EXISTS status;
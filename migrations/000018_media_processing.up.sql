@@ -0,0 +1,21 @@
+-- Images are stored as uploaded with status 'pending' and replaced by their processed version
+-- once a worker has claimed and processed them. Media uploaded before processing existed is ready.
+ALTER TABLE media ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'ready'
+    CHECK (status IN ('pending', 'processing', 'ready', 'failed'));
+ALTER TABLE media ADD COLUMN claimed_at TIMESTAMPTZ;
+ALTER TABLE media ADD COLUMN width INTEGER;
+ALTER TABLE media ADD COLUMN height INTEGER;
+ALTER TABLE media ADD COLUMN blurhash VARCHAR(64);
+
+CREATE INDEX media_unprocessed_created_at_idx ON media (created_at) WHERE status IN ('pending', 'processing');
+
+CREATE TABLE media_thumbnails (
+    media_id BIGINT NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    name VARCHAR(16) NOT NULL,
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    content_type VARCHAR(100) NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    size BIGINT NOT NULL,
+    PRIMARY KEY (media_id, name)
+);
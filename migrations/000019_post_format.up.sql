@@ -0,0 +1,4 @@
+ALTER TABLE posts ADD COLUMN format VARCHAR(16) NOT NULL DEFAULT 'plain'
+    CHECK (format IN ('plain', 'markdown'));
+-- content_html caches the rendering of Markdown posts, so reads don't render them again.
+ALTER TABLE posts ADD COLUMN content_html TEXT;
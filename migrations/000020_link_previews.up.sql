@@ -0,0 +1,18 @@
+-- link_previews caches the preview of each URL, shared by every post linking to it. fetched_at
+-- is when it was last fetched, or claimed for fetching while status is 'pending'.
+CREATE TABLE link_previews (
+    url TEXT PRIMARY KEY,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    title TEXT,
+    description TEXT,
+    image_url TEXT,
+    site_name TEXT,
+    fetched_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE post_links (
+    post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    position SMALLINT NOT NULL,
+    PRIMARY KEY (post_id, url)
+);
@@ -0,0 +1,6 @@
+ALTER TABLE home_timelines DROP COLUMN IF EXISTS repost_id;
+
+DROP TABLE IF EXISTS reposts;
+
+DROP INDEX IF EXISTS posts_quote_of_idx;
+ALTER TABLE posts DROP COLUMN IF EXISTS quote_of;
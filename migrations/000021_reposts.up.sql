@@ -0,0 +1,20 @@
+-- A quote post is an ordinary post that embeds the post it quotes. It keeps its content when the
+-- quoted post is purged.
+ALTER TABLE posts ADD COLUMN quote_of BIGINT REFERENCES posts(id) ON DELETE SET NULL;
+
+CREATE INDEX posts_quote_of_idx ON posts (quote_of) WHERE quote_of IS NOT NULL;
+
+CREATE TABLE reposts (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, post_id)
+);
+
+CREATE INDEX reposts_post_id_idx ON reposts (post_id);
+CREATE INDEX reposts_user_id_created_at_post_id_idx ON reposts (user_id, created_at DESC, post_id DESC);
+
+-- Timeline entries with a repost_id got there because author_id, a followed account, reposted
+-- the post; created_at is then the time of the repost. They go away with the repost.
+ALTER TABLE home_timelines ADD COLUMN repost_id BIGINT REFERENCES reposts(id) ON DELETE CASCADE;
@@ -0,0 +1,25 @@
+-- Bookmarks and their collections are private to the user who made them. Deleting a collection
+-- keeps its bookmarks, unsorted.
+CREATE TABLE bookmark_collections (
+    id BIGSERIAL PRIMARY KEY,
+    public_id CHAR(26) NOT NULL UNIQUE DEFAULT generate_ulid(),
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
+
+CREATE INDEX bookmark_collections_user_id_created_at_id_idx ON bookmark_collections (user_id, created_at DESC, id DESC);
+
+CREATE TABLE bookmarks (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    collection_id BIGINT REFERENCES bookmark_collections(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, post_id)
+);
+
+CREATE INDEX bookmarks_user_id_created_at_post_id_idx ON bookmarks (user_id, created_at DESC, post_id DESC);
+CREATE INDEX bookmarks_collection_id_created_at_post_id_idx ON bookmarks (collection_id, created_at DESC, post_id DESC)
+    WHERE collection_id IS NOT NULL;
+CREATE INDEX bookmarks_post_id_idx ON bookmarks (post_id);
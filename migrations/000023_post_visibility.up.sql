@@ -0,0 +1,4 @@
+-- Followers-only posts are visible to the author's followers and mentioned-only posts to the
+-- users they mention. The users mentioned in a post can always see it, as can its author.
+ALTER TABLE posts ADD COLUMN visibility VARCHAR(16) NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'followers', 'mentioned'));
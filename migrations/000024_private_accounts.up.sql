@@ -0,0 +1,14 @@
+-- Following a private account takes a follow request the account approves or rejects. Only its
+-- approved followers, and users it mentions, see its posts.
+ALTER TABLE users ADD COLUMN is_private BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE follow_requests (
+    follower_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX follow_requests_followee_id_created_at_follower_id_idx ON follow_requests (followee_id, created_at DESC, follower_id DESC);
+CREATE INDEX follow_requests_follower_id_created_at_followee_id_idx ON follow_requests (follower_id, created_at DESC, followee_id DESC);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS comment_reactions_comment_id_created_at_id_idx;
+DROP INDEX IF EXISTS post_reactions_post_id_created_at_id_idx;
+CREATE INDEX post_reactions_post_id_created_at_user_id_idx ON post_reactions (post_id, created_at DESC, user_id DESC);
+CREATE INDEX comment_reactions_comment_id_created_at_user_id_idx ON comment_reactions (comment_id, created_at DESC, user_id DESC);
+
+ALTER TABLE comment_reactions DROP COLUMN IF EXISTS id;
+ALTER TABLE post_reactions DROP COLUMN IF EXISTS id;
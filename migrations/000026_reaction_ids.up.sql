@@ -0,0 +1,9 @@
+-- A user can react to the same post or comment with several kinds at once, so (created_at,
+-- user_id) doesn't identify a reaction. The id breaks ties when paging through reactors.
+ALTER TABLE post_reactions ADD COLUMN id BIGINT GENERATED ALWAYS AS IDENTITY;
+ALTER TABLE comment_reactions ADD COLUMN id BIGINT GENERATED ALWAYS AS IDENTITY;
+
+DROP INDEX post_reactions_post_id_created_at_user_id_idx;
+DROP INDEX comment_reactions_comment_id_created_at_user_id_idx;
+CREATE INDEX post_reactions_post_id_created_at_id_idx ON post_reactions (post_id, created_at DESC, id DESC);
+CREATE INDEX comment_reactions_comment_id_created_at_id_idx ON comment_reactions (comment_id, created_at DESC, id DESC);
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS fanout_skipped;
+
+DROP TABLE IF EXISTS deliveries;
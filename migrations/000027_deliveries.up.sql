@@ -0,0 +1,19 @@
+-- Deliveries are the side effects of publishing a post or making a repost that are still to run:
+-- notifying the users it concerns and fanning it out into home timelines. They are recorded in
+-- the transaction that publishes, and deleted once done, so work whose job was lost to a full
+-- queue or a restart is retried. Bumping version asks for a delivery to run again.
+CREATE TABLE deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    post_id BIGINT UNIQUE REFERENCES posts(id) ON DELETE CASCADE,
+    repost_id BIGINT UNIQUE REFERENCES reposts(id) ON DELETE CASCADE,
+    version INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    claimed_at TIMESTAMPTZ,
+    CHECK ((post_id IS NULL) <> (repost_id IS NULL))
+);
+
+CREATE INDEX deliveries_due_idx ON deliveries (COALESCE(claimed_at, created_at));
+
+-- Accounts that ever had a post or repost left out of fan-out for being over the follower limit
+-- keep being merged into home timelines at read time, even after dropping back under it.
+ALTER TABLE users ADD COLUMN fanout_skipped BOOLEAN NOT NULL DEFAULT FALSE;
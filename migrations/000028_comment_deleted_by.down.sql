@@ -0,0 +1 @@
+ALTER TABLE comments DROP COLUMN IF EXISTS deleted_by;
@@ -0,0 +1,3 @@
+-- As with posts, deleted_by is set to whoever deleted the comment: authors can restore only what
+-- they deleted themselves.
+ALTER TABLE comments ADD COLUMN deleted_by BIGINT REFERENCES users(id) ON DELETE SET NULL;
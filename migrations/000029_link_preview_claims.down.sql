@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS link_previews_pending_idx;
+
+UPDATE link_previews SET fetched_at = COALESCE(claimed_at, created_at) WHERE fetched_at IS NULL;
+ALTER TABLE link_previews ALTER COLUMN fetched_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE link_previews ALTER COLUMN fetched_at SET NOT NULL;
+ALTER TABLE link_previews DROP COLUMN IF EXISTS claimed_at;
+ALTER TABLE link_previews DROP COLUMN IF EXISTS created_at;
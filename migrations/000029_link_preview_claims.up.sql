@@ -0,0 +1,12 @@
+-- A preview is claimed for fetching by setting claimed_at rather than fetched_at, so that a claim
+-- left behind by a lost job can be taken over, and so that a preview being refreshed keeps its
+-- status and is still served. fetched_at is null until the first fetch finishes.
+ALTER TABLE link_previews ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE link_previews ADD COLUMN claimed_at TIMESTAMPTZ;
+ALTER TABLE link_previews ALTER COLUMN fetched_at DROP NOT NULL;
+ALTER TABLE link_previews ALTER COLUMN fetched_at DROP DEFAULT;
+UPDATE link_previews SET created_at = fetched_at;
+UPDATE link_previews SET claimed_at = fetched_at, fetched_at = NULL WHERE status = 'pending';
+
+CREATE INDEX link_previews_pending_idx ON link_previews (COALESCE(claimed_at, created_at))
+    WHERE status = 'pending';
@@ -0,0 +1,28 @@
+CREATE OR REPLACE FUNCTION generate_ulid()
+RETURNS TEXT AS $$
+DECLARE
+    encoding TEXT = '0123456789ABCDEFGHJKMNPQRSTVWXYZ';
+    unix_time BIGINT;
+    ulid BYTEA;
+    output TEXT = '';
+    idx INTEGER;
+    bit INTEGER;
+BEGIN
+    unix_time = (EXTRACT(EPOCH FROM CLOCK_TIMESTAMP()) * 1000)::BIGINT;
+    ulid = DECODE(LPAD(TO_HEX(unix_time), 12, '0'), 'hex') || SUBSTRING(uuid_send(gen_random_uuid()) FROM 1 FOR 10);
+    -- 26 characters of 5 bits cover 130 bits, so the first character only carries the top 3 bits.
+    FOR i IN 0..25 LOOP
+        idx = 0;
+        FOR j IN 0..4 LOOP
+            bit = i * 5 + j - 2;
+            idx = idx << 1;
+            IF bit >= 0 THEN
+                -- get_bit numbers bits from the least significant end of each byte.
+                idx = idx | GET_BIT(ulid, (bit / 8) * 8 + 7 - bit % 8);
+            END IF;
+        END LOOP;
+        output = output || SUBSTRING(encoding FROM idx + 1 FOR 1);
+    END LOOP;
+    RETURN output;
+END;
+$$ language 'plpgsql' VOLATILE;